@@ -0,0 +1,95 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/saucelabs/webserver/internal/middleware"
+)
+
+//////
+// Definitions.
+//////
+
+// Admin settings. Built-in operational handlers (e.g.: liveness, readiness,
+// metrics, and stop) are served by a dedicated listener, leaving the main
+// router only with business traffic. It shares the server TLS settings.
+type Admin struct {
+	// Address to listen on, same forms as the server `Address`, e.g.:
+	// `127.0.0.1:9090`.
+	Address string `json:"address" validate:"required,listen_address"`
+
+	// ReadTimeout max duration for READING the entire request, including the
+	// body, default: 3s.
+	ReadTimeout time.Duration `json:"read_timeout"`
+
+	// RequestTimeout max duration to WAIT BEFORE CANCELING A REQUEST,
+	// default: 1s.
+	RequestTimeout time.Duration `json:"request_timeout" validate:"ltfield=ReadTimeout"`
+
+	// WriteTimeout max duration for WRITING the response, default: 3s.
+	WriteTimeout time.Duration `json:"write_timeout"`
+}
+
+//////
+// Server.
+//////
+
+// GetAdminRouter returns the router for built-in operational handlers. It's
+// the admin router if `WithAdminAddress` is set, otherwise the base router.
+func (s *Server) GetAdminRouter() *mux.Router {
+	if s.adminRouter != nil {
+		return s.adminRouter
+	}
+
+	return s.GetRouter()
+}
+
+// AdminAddr returns the address the admin listener is listening on, e.g.: the
+// actual port when listening on ":0". Before that, it's the configured
+// address. It's empty if `WithAdminAddress` isn't set.
+func (s *Server) AdminAddr() string {
+	if s.Admin == nil {
+		return ""
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.adminAddr == nil {
+		return s.Admin.Address
+	}
+
+	return formatAddr(s.adminAddr)
+}
+
+// Sets up the admin router, and HTTP server.
+func (s *Server) setupAdmin() {
+	s.adminRouter = mux.NewRouter()
+
+	s.adminRouter.Use(middleware.Logger(s.loggerController.RequestWriter(s.logger)))
+
+	s.adminServer = s.newHTTPServer(
+		s.Admin.Address,
+		s.timeoutHandler(s.adminRouter, func() time.Duration { return s.Admin.RequestTimeout }),
+		s.Admin.ReadTimeout,
+		s.Admin.WriteTimeout,
+	)
+}
+
+//////
+// Factory.
+//////
+
+// Returns admin settings with defaults.
+func newAdmin() *Admin {
+	return &Admin{
+		ReadTimeout:    defaultTimeout,
+		RequestTimeout: defaultRequestTimeout,
+		WriteTimeout:   defaultTimeout,
+	}
+}
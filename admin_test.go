@@ -0,0 +1,121 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/metric"
+)
+
+func TestNew_adminAddress(t *testing.T) {
+	readinessDeterminer := handler.NewReadinessDeterminer("db")
+	readinessDeterminer.SetReadiness(true)
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithAdminAddress("127.0.0.1:0"),
+		WithAdminTimeout(3*time.Second, 1*time.Second, 3*time.Second),
+		WithHandlers(handler.OK()),
+		WithMetrics(metric.Metric{Name: "admin_metric", Value: metric.NewInt("admin_metric_counter")}),
+		WithReadiness(readinessDeterminer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testServer.(*Server).addHandler(
+		testServer.GetAdminRouter(),
+		handler.Liveness(),
+		handler.Stop(testServer, handler.WithStopWait()),
+	)
+
+	serverErr := make(chan error, 1)
+
+	go func() {
+		serverErr <- testServer.Start()
+	}()
+
+	addr := waitReady(t, testServer)
+	adminAddr := testServer.AdminAddr()
+
+	if adminAddr == addr || adminAddr == "127.0.0.1:0" {
+		t.Fatalf("Expected a dedicated admin listener, got %s", adminAddr)
+	}
+
+	t.Run("Should work - business traffic, main router", func(t *testing.T) {
+		callAndExpect(t, addr, "", http.StatusOK, "OK")
+		callAndExpect(t, adminAddr, "", http.StatusNotFound, "")
+	})
+
+	t.Run("Should work - operational handlers, admin router", func(t *testing.T) {
+		for _, path := range []string{"liveness", "readiness", "debug/vars", "stop"} {
+			callAndExpect(t, addr, path, http.StatusNotFound, "")
+		}
+
+		callAndExpect(t, adminAddr, "liveness", http.StatusOK, "OK")
+		callAndExpect(t, adminAddr, "readiness", http.StatusOK, "OK")
+		callAndExpect(t, adminAddr, "debug/vars", http.StatusOK, "admin_metric")
+	})
+
+	t.Run("Should work - stop, admin router, waits for shutdown", func(t *testing.T) {
+		requestAndExpect(t, http.MethodPost, adminAddr, "stop", nil, http.StatusOK, "stopped")
+
+		select {
+		case err := <-serverErr:
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected server to stop")
+		}
+	})
+}
+
+func TestNew_adminAddress_serveError(t *testing.T) {
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithAdminAddress("127.0.0.1:0"),
+		WithAdminTimeout(3*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testServer.(*Server).addHandler(testServer.GetAdminRouter(), handler.Liveness())
+
+	serverErr := make(chan error, 1)
+
+	go func() {
+		serverErr <- testServer.Start()
+	}()
+
+	waitReady(t, testServer)
+
+	adminAddr := testServer.AdminAddr()
+
+	callAndExpect(t, adminAddr, "liveness", http.StatusOK, "OK")
+
+	// Makes the main server fail serving.
+	testServer.(*Server).m.Lock()
+	testServer.(*Server).listener.Close()
+	testServer.(*Server).m.Unlock()
+
+	select {
+	case err := <-serverErr:
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			t.Fatalf("Expected serve error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected server to stop")
+	}
+
+	if _, err := c.Get(fmt.Sprintf("http://%s/liveness", adminAddr)); err == nil {
+		t.Fatal("Expected admin server to be stopped too")
+	}
+}
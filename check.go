@@ -0,0 +1,57 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+
+	"github.com/saucelabs/webserver/metric"
+)
+
+//////
+// Server.
+//////
+
+// Starts readiness checks once the server starts, and cancels them once it's
+// shutting down, or stopped. Shutdown waits for them, see the `checks`
+// shutdown task.
+func (s *Server) setupChecks() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.OnStateChange(func(_, to State) {
+		switch to {
+		case StateStarting:
+			for _, check := range s.checks {
+				check.Start(ctx)
+			}
+		case StateDraining, StateStopped:
+			// State changes shouldn't block, running checks are waited by
+			// the shutdown task.
+			cancel()
+		}
+	})
+
+	s.OnShutdown("checks", func(context.Context) error {
+		for _, check := range s.checks {
+			check.Stop()
+		}
+
+		return nil
+	})
+}
+
+// Publishes readiness checks metrics, per check.
+func (s *Server) publishCheckMetrics() error {
+	checks, err := publishedMap(s.Name+"_checks", new(metric.Map).Init())
+	if err != nil {
+		return err
+	}
+
+	for _, check := range s.checks {
+		checks.Set(check.GetName(), check.Metrics())
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package check
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/handler"
+)
+
+//////
+// Consts, and vars.
+//////
+
+// HTTP client which doesn't follow redirects, e.g.: to a login page, so their
+// status code is checked.
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+//////
+// Network.
+//////
+
+// TCP checks a TCP connection to `address`, e.g.: `127.0.0.1:5432`, can be
+// established.
+func TCP(address string) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return customerror.NewFailedToError("dial "+address, customerror.WithError(err))
+		}
+
+		return conn.Close()
+	}
+}
+
+// HTTP checks a `GET` request to `url` responds with a status code within
+// `minStatusCode`, and `maxStatusCode` (inclusive), e.g.: 200, and 299.
+// Redirects aren't followed, see `HTTPClient`.
+func HTTP(url string, minStatusCode, maxStatusCode int) handler.CheckFunc {
+	return HTTPClient(noRedirectClient, url, minStatusCode, maxStatusCode)
+}
+
+// HTTPClient is like `HTTP`, but requests via `client`, e.g.: one with TLS
+// client certificates, or which follows redirects.
+func HTTPClient(client *http.Client, url string, minStatusCode, maxStatusCode int) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return customerror.NewInvalidError("url "+url, customerror.WithError(err))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return customerror.NewFailedToError("request "+url, customerror.WithError(err))
+		}
+
+		defer resp.Body.Close()
+
+		// Allows the connection to be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+		if resp.StatusCode < minStatusCode || resp.StatusCode > maxStatusCode {
+			return customerror.NewInvalidError(fmt.Sprintf(
+				"status code %d from %s, expected %d-%d",
+				resp.StatusCode, url, minStatusCode, maxStatusCode,
+			))
+		}
+
+		return nil
+	}
+}
+
+// DNS checks `host` resolves to at least one address.
+func DNS(host string) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return customerror.NewFailedToError("resolve "+host, customerror.WithError(err))
+		}
+
+		if len(addrs) == 0 {
+			return customerror.NewFailedToError("resolve " + host + ", no addresses")
+		}
+
+		return nil
+	}
+}
+
+//////
+// Filesystem.
+//////
+
+// Exists checks a file, or directory exists at `path`.
+func Exists(path string) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			return customerror.NewMissingError(path, customerror.WithError(err))
+		}
+
+		return nil
+	}
+}
+
+// Writable checks `path` is writable. If it's a directory, a temporary file is
+// created, and removed. If it's a file, it's opened for appending.
+func Writable(path string) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return customerror.NewMissingError(path, customerror.WithError(err))
+		}
+
+		if !info.IsDir() {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				return customerror.NewFailedToError("write to "+path, customerror.WithError(err))
+			}
+
+			if err := f.Close(); err != nil {
+				return customerror.NewFailedToError("write to "+path, customerror.WithError(err))
+			}
+
+			return nil
+		}
+
+		f, err := os.CreateTemp(path, ".check-*")
+		if err != nil {
+			return customerror.NewFailedToError("write to "+path, customerror.WithError(err))
+		}
+
+		// Removed even if closing fails, e.g.: disk full.
+		closeErr := f.Close()
+
+		if err := os.Remove(f.Name()); err != nil {
+			return customerror.NewFailedToError("remove "+f.Name(), customerror.WithError(err))
+		}
+
+		if closeErr != nil {
+			return customerror.NewFailedToError("write to "+path, customerror.WithError(closeErr))
+		}
+
+		return nil
+	}
+}
+
+// DiskFree checks the filesystem holding `path` has at least `minBytes`
+// available.
+func DiskFree(path string, minBytes uint64) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		available, _, err := diskSpace(path)
+		if err != nil {
+			return customerror.NewFailedToError("get disk space of "+path, customerror.WithError(err))
+		}
+
+		if available < minBytes {
+			return customerror.NewInvalidError(fmt.Sprintf(
+				"disk space of %s, %d bytes available, expected at least %d",
+				path, available, minBytes,
+			))
+		}
+
+		return nil
+	}
+}
+
+// DiskFreePercent checks the filesystem holding `path` has at least
+// `minPercent` (0-100) of its space available.
+func DiskFreePercent(path string, minPercent float64) handler.CheckFunc {
+	return func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		available, total, err := diskSpace(path)
+		if err != nil {
+			return customerror.NewFailedToError("get disk space of "+path, customerror.WithError(err))
+		}
+
+		percent := 100.0
+
+		if total > 0 {
+			percent = float64(available) / float64(total) * 100
+		}
+
+		if percent < minPercent {
+			return customerror.NewInvalidError(fmt.Sprintf(
+				"disk space of %s, %.1f%% available, expected at least %.1f%%",
+				path, percent, minPercent,
+			))
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package check
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+func TestChecks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	// An address nothing listens to.
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closedAddr := closedListener.Addr().String()
+
+	closedListener.Close()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer testServer.Close()
+
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file")
+
+	if err := os.WriteFile(file, []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		check    handler.CheckFunc
+		canceled bool
+		wantErr  bool
+	}{
+		{
+			name:    "Should work - TCP",
+			check:   TCP(listener.Addr().String()),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - TCP, nothing listening",
+			check:   TCP(closedAddr),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - HTTP",
+			check:   HTTP(testServer.URL, 200, 299),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - HTTP, status code out of range",
+			check:   HTTP(testServer.URL+"/down", 200, 299),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - HTTP, redirects aren't followed",
+			check:   HTTP(testServer.URL+"/redirect", 300, 399),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - HTTP, redirects aren't followed",
+			check:   HTTP(testServer.URL+"/redirect", 200, 299),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - HTTPClient, redirects are followed",
+			check:   HTTPClient(http.DefaultClient, testServer.URL+"/redirect", 200, 299),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - HTTP, nothing listening",
+			check:   HTTP("http://"+closedAddr, 200, 299),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - DNS",
+			check:   DNS("localhost"),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - DNS",
+			check:   DNS("nonexistent.invalid"),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - Exists, file",
+			check:   Exists(file),
+			wantErr: false,
+		},
+		{
+			name:    "Should work - Exists, dir",
+			check:   Exists(dir),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - Exists",
+			check:   Exists(filepath.Join(dir, "nonexistent")),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - Writable, file",
+			check:   Writable(file),
+			wantErr: false,
+		},
+		{
+			name:    "Should work - Writable, dir",
+			check:   Writable(dir),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - Writable",
+			check:   Writable(filepath.Join(dir, "nonexistent")),
+			wantErr: true,
+		},
+		{
+			name:     "Should fail - Exists, canceled",
+			check:    Exists(file),
+			canceled: true,
+			wantErr:  true,
+		},
+		{
+			name:     "Should fail - Writable, canceled",
+			check:    Writable(dir),
+			canceled: true,
+			wantErr:  true,
+		},
+		{
+			name:    "Should work - DiskFree",
+			check:   DiskFree(dir, 0),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - DiskFree",
+			check:   DiskFree(dir, math.MaxUint64),
+			wantErr: true,
+		},
+		{
+			name:    "Should work - DiskFreePercent",
+			check:   DiskFreePercent(dir, 0),
+			wantErr: false,
+		},
+		{
+			name:    "Should fail - DiskFreePercent",
+			check:   DiskFreePercent(dir, 101),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if tt.canceled {
+				cancel()
+			}
+
+			if err := tt.check(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Should work - Writable leaves nothing behind", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("Expected only the test file, got %d entries", len(entries))
+		}
+	})
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd
+
+package check
+
+import "syscall"
+
+// Returns the space, in bytes, available to unprivileged users, and the total
+// of the filesystem holding `path`.
+func diskSpace(path string) (available, total uint64, err error) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	//nolint:unconvert // Field types vary per platform.
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
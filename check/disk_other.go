@@ -0,0 +1,18 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd
+
+package check
+
+import (
+	"runtime"
+
+	"github.com/saucelabs/customerror"
+)
+
+// Disk space isn't supported on this platform.
+func diskSpace(path string) (available, total uint64, err error) {
+	return 0, 0, customerror.New("disk space isn't supported on " + runtime.GOOS)
+}
@@ -0,0 +1,21 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package check provides a collection of ready-made dependency checks: TCP,
+// HTTP, DNS, filesystem, and disk free space. They're meant to be run by a
+// readiness determiner, e.g.:
+//
+//	db, err := handler.NewCheckDeterminer(
+//		"db",
+//		check.TCP("127.0.0.1:5432"),
+//		10*time.Second,
+//		time.Second,
+//		handler.CheckThresholds{},
+//	)
+//
+// See `webserver.WithChecks`.
+//
+// NOTE: Filesystem, and disk checks can't be interrupted once started, they
+// only give up if the check context is already done.
+package check
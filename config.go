@@ -0,0 +1,410 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/validation"
+	"gopkg.in/yaml.v2"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const (
+	// ConfigFormatJSON is the JSON configuration format.
+	ConfigFormatJSON = "json"
+
+	// ConfigFormatYAML is the YAML configuration format.
+	ConfigFormatYAML = "yaml"
+
+	// Overrides the configured address.
+	envAddress = "WEBSERVER_ADDRESS"
+
+	// Overrides the configured name.
+	envName = "WEBSERVER_NAME"
+
+	// Overrides the port of the configured address, e.g.: set by PaaS.
+	envPort = "PORT"
+)
+
+//////
+// Definitions.
+//////
+
+// Config is the server configuration, loadable from JSON, or YAML, see
+// `LoadConfig`. Durations are either strings, e.g.: "3s", or nanoseconds.
+// Settings mirror the `Server` ones, by JSON name, and type.
+type Config struct {
+	// Address to listen on, see `Server.Address`.
+	Address string `json:"address" validate:"required,listen_address"`
+
+	// Admin enables a dedicated listener for built-in operational handlers.
+	Admin *Admin `json:"admin" validate:"omitempty"`
+
+	// EnableMetrics controls whether metrics are enable, or not.
+	EnableMetrics bool `json:"enable_metrics"`
+
+	// EnableSystemdNotify controls whether systemd is notified, or not.
+	EnableSystemdNotify bool `json:"enable_systemd_notify"`
+
+	// EnableTelemetry controls whether telemetry are enable, or not.
+	EnableTelemetry bool `json:"enable_telemetry"`
+
+	// MaxHeaderBytes max size of the request headers.
+	MaxHeaderBytes int `json:"max_header_bytes" validate:"gt=0"`
+
+	// Name of the server.
+	Name string `json:"name" validate:"required,gte=3"`
+
+	// ParallelShutdownTasks controls whether shutdown tasks run in parallel.
+	ParallelShutdownTasks bool `json:"parallel_shutdown_tasks"`
+
+	// ReadinessOverrides forces the readiness state of determiners, by name,
+	// e.g.: taking an instance out of rotation. See `WithReadiness`. The
+	// server state can't be overridden.
+	ReadinessOverrides map[string]bool `json:"readiness_overrides"`
+
+	// SocketMode is the Unix domain socket permissions, either an octal
+	// string, e.g.: "0600", or an integer.
+	SocketMode os.FileMode `json:"socket_mode"`
+
+	// Logging fine-control.
+	Logging *Logging `json:"logging" validate:"required"`
+
+	// Timeouts fine-control.
+	Timeout *Timeout `json:"timeout" validate:"required"`
+
+	// TLS enables HTTPS, and optionally mutual TLS.
+	TLS *TLS `json:"tls" validate:"omitempty"`
+}
+
+//////
+// Config.
+//////
+
+// Returns the index of `t` (a struct) fields, by JSON name. Fields without
+// one are skipped.
+func jsonFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields[name] = i
+	}
+
+	return fields
+}
+
+// Option returns an option which applies the configuration to the server. The
+// server gets its own copy of it. Settings are matched to the server ones by
+// JSON name, so they can't drift.
+func (c *Config) Option() Option {
+	return func(s *Server) {
+		cv := reflect.ValueOf(c).Elem()
+		sv := reflect.ValueOf(s).Elem()
+
+		serverFields := jsonFields(sv.Type())
+
+		for name, i := range jsonFields(cv.Type()) {
+			j, ok := serverFields[name]
+			if !ok {
+				continue
+			}
+
+			v := cv.Field(i)
+
+			if v.Kind() == reflect.Ptr && !v.IsNil() {
+				cp := reflect.New(v.Elem().Type())
+				cp.Elem().Set(v.Elem())
+
+				v = cp
+			}
+
+			sv.Field(j).Set(v)
+		}
+
+		// Not a server setting, see `Reload`.
+		s.readinessOverrides = c.ReadinessOverrides
+	}
+}
+
+// Overrides settings from environment variables: `WEBSERVER_ADDRESS`,
+// `WEBSERVER_NAME`, and `PORT` - which replaces the port of TCP addresses.
+func (c *Config) applyEnv() {
+	if address := os.Getenv(envAddress); address != "" {
+		c.Address = address
+	}
+
+	if name := os.Getenv(envName); name != "" {
+		c.Name = name
+	}
+
+	port := os.Getenv(envPort)
+
+	// Only TCP addresses have a port.
+	if port == "" ||
+		strings.HasPrefix(c.Address, unixAddressPrefix) ||
+		strings.HasPrefix(c.Address, systemdAddressPrefix) {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		host = c.Address
+	}
+
+	c.Address = net.JoinHostPort(host, port)
+}
+
+//////
+// Durations.
+//////
+
+// Decodes JSON `data` into `v`, a pointer to a struct, accepting duration
+// strings, e.g.: "3s", for `time.Duration` fields.
+func unmarshalWithDurations(data []byte, v interface{}) error {
+	raw := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(v).Elem()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Type != reflect.TypeOf(time.Duration(0)) {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		var str string
+
+		if value, ok := raw[name]; !ok || json.Unmarshal(value, &str) != nil {
+			continue
+		}
+
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return customerror.NewInvalidError(fmt.Sprintf("%s, %q isn't a duration", name, str))
+		}
+
+		raw[name] = json.RawMessage(fmt.Sprint(int64(d)))
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+// UnmarshalJSON accepts the socket mode as an octal string, e.g.: "0600".
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+
+	raw := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var mode string
+
+	if value, ok := raw["socket_mode"]; ok && json.Unmarshal(value, &mode) == nil {
+		m, err := strconv.ParseUint(strings.TrimPrefix(mode, "0o"), 8, 32)
+		if err != nil {
+			return customerror.NewInvalidError(fmt.Sprintf("socket_mode, %q isn't an octal file mode, e.g.: \"0600\"", mode))
+		}
+
+		raw["socket_mode"] = json.RawMessage(fmt.Sprint(m))
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode((*config)(c))
+}
+
+// UnmarshalJSON accepts duration strings, e.g.: "3s".
+func (t *Timeout) UnmarshalJSON(data []byte) error {
+	type timeout Timeout
+
+	return unmarshalWithDurations(data, (*timeout)(t))
+}
+
+// UnmarshalJSON accepts duration strings, e.g.: "10s". Omitted settings
+// default, see `WithTLS`.
+func (t *TLS) UnmarshalJSON(data []byte) error {
+	type tlsSettings TLS
+
+	if t.CertFile == "" && t.KeyFile == "" && t.ReloadInterval == 0 {
+		t.ReloadInterval = defaultTLSReloadInterval
+	}
+
+	return unmarshalWithDurations(data, (*tlsSettings)(t))
+}
+
+// UnmarshalJSON accepts duration strings, e.g.: "3s". Omitted settings
+// default, see `WithAdminAddress`.
+func (a *Admin) UnmarshalJSON(data []byte) error {
+	type admin Admin
+
+	if *a == (Admin{}) {
+		*a = *newAdmin()
+	}
+
+	return unmarshalWithDurations(data, (*admin)(a))
+}
+
+//////
+// YAML.
+//////
+
+// Converts YAML decoded values to JSON compatible ones, e.g.: map keys to
+// strings.
+func yamlToJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+
+		for k, item := range value {
+			m[fmt.Sprint(k)] = yamlToJSONValue(item)
+		}
+
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = yamlToJSONValue(item)
+		}
+
+		return value
+	default:
+		return value
+	}
+}
+
+//////
+// Factory.
+//////
+
+// LoadConfig loads the configuration from `r`, in the `format` (either
+// `ConfigFormatJSON`, or `ConfigFormatYAML`) over defaults. Environment
+// variables override it (`WEBSERVER_ADDRESS`, `WEBSERVER_NAME`, and `PORT`).
+// The result is validated. Unknown fields are rejected.
+//
+// NOTE: Only the address, and the name have environment overrides, other
+// fields, e.g.: timeouts, or logging are only set from `r`.
+func LoadConfig(r io.Reader, format string) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, customerror.NewFailedToError("read config", customerror.WithError(err))
+	}
+
+	switch strings.ToLower(format) {
+	case ConfigFormatJSON:
+	case ConfigFormatYAML, "yml":
+		var v interface{}
+
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, customerror.NewInvalidError("config, YAML", customerror.WithError(err))
+		}
+
+		if data, err = json.Marshal(yamlToJSONValue(v)); err != nil {
+			return nil, customerror.NewInvalidError("config, YAML", customerror.WithError(err))
+		}
+	default:
+		return nil, customerror.NewInvalidError("config format " + format + ", it's either json, or yaml")
+	}
+
+	c := &Config{
+		MaxHeaderBytes: defaultMaxHeaderBytes,
+		SocketMode:     defaultSocketMode,
+		Logging:        newLogging(),
+		Timeout:        newTimeout(),
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(c); err != nil {
+		return nil, customerror.NewInvalidError("config", customerror.WithError(err))
+	}
+
+	c.applyEnv()
+
+	if err := validation.ValidateStruct(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// LoadConfigFile loads the configuration from the file at `path`, see
+// `LoadConfig`. The format is based on the extension: `.json`, `.yaml`, or
+// `.yml`.
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, customerror.NewFailedToError("open config", customerror.WithError(err))
+	}
+
+	defer f.Close()
+
+	return LoadConfig(f, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// NewFromConfig returns a basic web server, see `New`, configured from the
+// file at `path`, see `LoadConfigFile`. `opts` are applied after the
+// configuration, e.g.: handlers.
+//
+// The file is reloaded on `Reload`, and optionally once it changes, see
+// `WithConfigReloadInterval`. Log levels, log file, request timeouts, and
+// readiness overrides are applied at runtime, other changes are logged as
+// requiring a restart, including connection timeouts (read, read header,
+// write, and idle) - the HTTP server can't change them while serving. Invalid
+// configurations are rejected, keeping the current one.
+func NewFromConfig(path string, opts ...Option) (IServer, error) {
+	c, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	withConfigReloader := func(s *Server) {
+		s.configReloader = newConfigReloader(path, c)
+	}
+
+	return New(c.Name, c.Address, append([]Option{c.Option(), withConfigReloader}, opts...)...)
+}
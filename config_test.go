@@ -0,0 +1,430 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/validation"
+)
+
+const configJSON = `{
+	"address": "127.0.0.1:8080",
+	"name": "config-server",
+	"logging": {"console_level": "error", "request_level": "none"},
+	"timeout": {"read_timeout": "5s", "request_timeout": 2000000000},
+	"admin": {"address": "127.0.0.1:9090"}
+}`
+
+const configYAML = `
+address: 127.0.0.1:8080
+name: config-server
+logging:
+  console_level: error
+timeout:
+  read_timeout: 5s
+  request_timeout: 2s
+admin:
+  address: 127.0.0.1:9090
+`
+
+func TestLoadConfig(t *testing.T) {
+	type args struct {
+		content string
+		format  string
+		env     map[string]string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantAddress string
+		wantErr     bool
+	}{
+		{
+			name:        "Should work - JSON",
+			args:        args{content: configJSON, format: ConfigFormatJSON},
+			wantAddress: "127.0.0.1:8080",
+		},
+		{
+			name:        "Should work - YAML",
+			args:        args{content: configYAML, format: ConfigFormatYAML},
+			wantAddress: "127.0.0.1:8080",
+		},
+		{
+			name: "Should work - env overrides",
+			args: args{
+				content: configYAML,
+				format:  "yml",
+				env:     map[string]string{envAddress: "0.0.0.0:8080", envPort: "3000"},
+			},
+			wantAddress: "0.0.0.0:3000",
+		},
+		{
+			name:    "Should fail - invalid format",
+			args:    args{content: configJSON, format: "toml"},
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - unknown field",
+			args:    args{content: `{"address": "127.0.0.1:8080", "name": "config-server", "nope": true}`, format: ConfigFormatJSON},
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - invalid duration",
+			args:    args{content: strings.Replace(configYAML, "read_timeout: 5s", "read_timeout: 5 seconds", 1), format: ConfigFormatYAML},
+			wantErr: true,
+		},
+		{
+			name:    "Should fail - validation",
+			args:    args{content: strings.Replace(configYAML, "console_level: error", "console_level: loud", 1), format: ConfigFormatYAML},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.args.env {
+				t.Setenv(k, v)
+			}
+
+			c, err := LoadConfig(strings.NewReader(tt.args.content), tt.args.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if c.Address != tt.wantAddress {
+				t.Fatalf("Expected %s got %s", tt.wantAddress, c.Address)
+			}
+
+			// Set values.
+			if c.Timeout.ReadTimeout != 5*time.Second || c.Timeout.RequestTimeout != 2*time.Second {
+				t.Fatalf("Expected durations to be loaded, got %v, %v", c.Timeout.ReadTimeout, c.Timeout.RequestTimeout)
+			}
+
+			// Defaults.
+			if c.Timeout.WriteTimeout != defaultTimeout || c.Admin.RequestTimeout != defaultRequestTimeout {
+				t.Fatalf("Expected defaults to be kept, got %v, %v", c.Timeout.WriteTimeout, c.Admin.RequestTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_socketMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"Should work - JSON, octal string", `"socket_mode": "0600"`, ConfigFormatJSON, 0o600, false},
+		{"Should work - JSON, octal string, prefixed", `"socket_mode": "0o640"`, ConfigFormatJSON, 0o640, false},
+		{"Should work - JSON, integer", `"socket_mode": 384`, ConfigFormatJSON, 0o600, false},
+		{"Should work - YAML, octal string", `socket_mode: "0600"`, ConfigFormatYAML, 0o600, false},
+		{"Should work - YAML, octal", `socket_mode: 0600`, ConfigFormatYAML, 0o600, false},
+		{"Should fail - JSON, not octal", `"socket_mode": "0900"`, ConfigFormatJSON, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `{"address": "127.0.0.1:8080", "name": "config-server", ` + tt.content + `}`
+
+			if tt.format == ConfigFormatYAML {
+				content = "address: 127.0.0.1:8080\nname: config-server\n" + tt.content + "\n"
+			}
+
+			c, err := LoadConfig(strings.NewReader(content), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && c.SocketMode != tt.want {
+				t.Fatalf("Expected %o got %o", tt.want, c.SocketMode)
+			}
+		})
+	}
+}
+
+// Config settings mirror the server ones, so they can't drift.
+func TestConfig_fields(t *testing.T) {
+	configType := reflect.TypeOf(Config{})
+	serverType := reflect.TypeOf(Server{})
+
+	configFields := jsonFields(configType)
+	serverFields := jsonFields(serverType)
+
+	for name, i := range serverFields {
+		j, ok := configFields[name]
+		if !ok {
+			t.Errorf("Expected server setting %s to be configurable", name)
+
+			continue
+		}
+
+		if configType.Field(j).Type != serverType.Field(i).Type {
+			t.Errorf("Expected %s to be %s got %s", name, serverType.Field(i).Type, configType.Field(j).Type)
+		}
+	}
+
+	for name := range configFields {
+		if _, ok := serverFields[name]; !ok && name != "readiness_overrides" {
+			t.Errorf("Expected setting %s to be a server setting", name)
+		}
+	}
+
+	t.Run("Should work - option copies every setting", func(t *testing.T) {
+		c, err := LoadConfig(strings.NewReader(configJSON), ConfigFormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.SocketMode = 0o600
+
+		s := &Server{}
+
+		c.Option()(s)
+
+		for name, i := range configFields {
+			j, ok := serverFields[name]
+			if !ok {
+				continue
+			}
+
+			if !reflect.DeepEqual(reflect.ValueOf(c).Elem().Field(i).Interface(), reflect.ValueOf(s).Elem().Field(j).Interface()) {
+				t.Errorf("Expected %s to be copied", name)
+			}
+		}
+
+		if s.Timeout == c.Timeout || s.Logging == c.Logging || s.Admin == c.Admin {
+			t.Error("Expected the server to get its own copy")
+		}
+	})
+}
+
+func TestLoadConfig_validationError(t *testing.T) {
+	content := strings.NewReplacer(
+		"console_level: error", "console_level: loud",
+		"request_timeout: 2s", "request_timeout: 6s",
+	).Replace(configYAML)
+
+	_, err := LoadConfig(strings.NewReader(content), ConfigFormatYAML)
+
+	var vE *validation.ValidationError
+
+	if !errors.As(err, &vE) {
+		t.Fatalf("Expected ValidationError got %v", err)
+	}
+
+	expected := []validation.FieldError{
+		{
+			Field:   "logging.console_level",
+			Rule:    "oneof",
+			Param:   "none fatal error info warn debug trace",
+			Value:   "loud",
+			Message: "console_level must be one of [none fatal error info warn debug trace]",
+		},
+		{
+			Field:   "timeout.request_timeout",
+			Rule:    "ltfield",
+			Param:   "read_timeout",
+			Value:   "6s",
+			Message: "request_timeout must be less than read_timeout",
+		},
+	}
+
+	if !reflect.DeepEqual(vE.Fields, expected) {
+		t.Fatalf("Expected %+v got %+v", expected, vE.Fields)
+	}
+
+	t.Run("Should work - rendered", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		handler.WriteError(w, httptest.NewRequest(http.MethodPost, "/", nil), err)
+
+		var body handler.ErrorResponse
+
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != http.StatusBadRequest || body.Message != "invalid data" || !reflect.DeepEqual(body.Fields, expected) {
+			t.Fatalf("Unexpected response %d %+v", w.Code, body)
+		}
+	})
+}
+
+func TestNewFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	content := strings.NewReplacer("127.0.0.1:8080", "127.0.0.1:0", "127.0.0.1:9090", "127.0.0.1:0").Replace(configYAML)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testServer, err := NewFromConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if testServer.(*Server).Name != "config-server" || testServer.(*Server).Timeout.ReadTimeout != 5*time.Second {
+		t.Fatal("Expected configuration to be applied")
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	waitReady(t, testServer)
+
+	if testServer.AdminAddr() == "127.0.0.1:0" {
+		t.Fatal("Expected admin listener to be listening")
+	}
+}
+
+func TestNewFromConfig_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	write := func(t *testing.T, content string, modTime time.Time) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Guarantees the change is noticed, regardless of the file system
+		// timestamps resolution.
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := strings.NewReplacer("127.0.0.1:8080", "127.0.0.1:0", "127.0.0.1:9090", "127.0.0.1:0").Replace(configYAML)
+
+	write(t, content, time.Now())
+
+	db := handler.NewReadinessDeterminer("db")
+	db.SetReadiness(true)
+
+	testServer, err := NewFromConfig(path, WithReadiness(db), WithConfigReloadInterval(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	waitReady(t, testServer)
+
+	adminAddr := testServer.AdminAddr()
+
+	requestTimeout := func() time.Duration {
+		return testServer.(*Server).requestTimeoutSettings().timeout
+	}
+
+	t.Run("Should work - hot-swappable settings", func(t *testing.T) {
+		write(t, strings.NewReplacer(
+			"request_timeout: 2s", "request_timeout: 500ms",
+			"console_level: error", "console_level: debug",
+		).Replace(content)+"readiness_overrides:\n  db: false\n", time.Now().Add(time.Second))
+
+		if err := testServer.Reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		if requestTimeout() != 500*time.Millisecond {
+			t.Fatalf("Expected %s got %s", 500*time.Millisecond, requestTimeout())
+		}
+
+		callAndExpect(t, adminAddr, "readiness", http.StatusServiceUnavailable, "db failed readiness")
+	})
+
+	t.Run("Should fail - invalid config, nothing changes", func(t *testing.T) {
+		write(t, strings.Replace(content, "console_level: error", "console_level: loud", 1), time.Now().Add(2*time.Second))
+
+		if err := testServer.Reload(); err == nil {
+			t.Fatal("Expected error")
+		}
+
+		if requestTimeout() != 500*time.Millisecond {
+			t.Fatalf("Expected %s got %s", 500*time.Millisecond, requestTimeout())
+		}
+
+		callAndExpect(t, adminAddr, "readiness", http.StatusServiceUnavailable, "db failed readiness")
+	})
+
+	t.Run("Should fail - request timeout isn't smaller than the current read timeout", func(t *testing.T) {
+		// Read timeout requires a restart.
+		write(t, strings.NewReplacer(
+			"read_timeout: 5s", "read_timeout: 10s",
+			"request_timeout: 2s", "request_timeout: 6s",
+		).Replace(content), time.Now().Add(3*time.Second))
+
+		if err := testServer.Reload(); err == nil {
+			t.Fatal("Expected error")
+		}
+
+		if requestTimeout() != 500*time.Millisecond {
+			t.Fatalf("Expected %s got %s", 500*time.Millisecond, requestTimeout())
+		}
+	})
+
+	t.Run("Should work - file change", func(t *testing.T) {
+		write(t, content, time.Now().Add(4*time.Second))
+
+		deadline := time.Now().Add(5 * time.Second)
+
+		for requestTimeout() != 2*time.Second {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected configuration to be reloaded")
+			}
+
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		callAndExpect(t, adminAddr, "readiness", http.StatusOK, http.StatusText(http.StatusOK))
+	})
+
+	t.Run("Should fail - server state can't be overridden", func(t *testing.T) {
+		write(t, content+"readiness_overrides:\n  config-server: true\n", time.Now().Add(5*time.Second))
+
+		if err := testServer.Reload(); err == nil {
+			t.Fatal("Expected error")
+		}
+	})
+
+	t.Run("Should work - overrides set in code are kept", func(t *testing.T) {
+		db.SetOverride(false)
+		defer db.ClearOverride()
+
+		write(t, content, time.Now().Add(6*time.Second))
+
+		if err := testServer.Reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := db.Status(); !status.Overridden || status.Ready {
+			t.Fatalf("Expected override set in code to be kept, got %+v", status)
+		}
+	})
+}
@@ -14,7 +14,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/saucelabs/randomness"
 	"github.com/saucelabs/webserver"
 	"github.com/saucelabs/webserver/handler"
 )
@@ -30,10 +29,10 @@ func logAndExit(msg string) {
 	os.Exit(1)
 }
 
-func callAndExpect(port int, url string, sc int, expectedBodyContains string) (int, string) {
+func callAndExpect(addr string, url string, sc int, expectedBodyContains string) (int, string) {
 	c := http.Client{Timeout: time.Duration(10) * time.Second}
 
-	resp, err := c.Get(fmt.Sprintf("http://0.0.0.0:%d/%s", port, url))
+	resp, err := c.Get(fmt.Sprintf("http://%s/%s", addr, url))
 	if err != nil {
 		logAndExit(err.Error())
 	}
@@ -77,16 +76,8 @@ func ExampleNew() {
 	readinessFlag := handler.NewReadinessDeterminer("tunnel")
 
 	// Golang's example are like tests, it's a bad practice to have a hardcoded
-	// port because of the possibility of collision. Generate a random port.
-	r, err := randomness.New(3000, 7000, 10, true)
-	if err != nil {
-		logAndExit(err.Error())
-	}
-
-	port := r.MustGenerate()
-
-	// Setup server settings some options.
-	testServer, err := webserver.New(serverName, fmt.Sprintf("0.0.0.0:%d", port),
+	// port because of the possibility of collision. Port `0` picks a free one.
+	testServer, err := webserver.New(serverName, "127.0.0.1:0",
 		// Sets server readiness.
 		webserver.WithReadiness(readinessFlag),
 	)
@@ -105,13 +96,13 @@ func ExampleNew() {
 		}
 	}()
 
-	// Ensures enough time for the server to be up, and ready - just for testing.
-	time.Sleep(3 * time.Second)
+	// Waits for the server to be up, and accepting connections.
+	<-testServer.Ready()
 
 	// Simulates a Readiness probe, for example, Kubernetes.
 	go func() {
 		for {
-			_, body := callAndExpect(int(port), "/readiness", 0, "")
+			_, body := callAndExpect(testServer.Addr(), "/readiness", 0, "")
 
 			probeResultsLocker.Lock()
 			probeResults = append(probeResults, body)
@@ -144,16 +135,8 @@ func ExampleNew() {
 
 func ExampleNewDefault() {
 	// Golang's example are like tests, it's a bad practice to have a hardcoded
-	// port because of the possibility of collision. Generate a random port.
-	r, err := randomness.New(3000, 7000, 10, true)
-	if err != nil {
-		logAndExit(err.Error())
-	}
-
-	port := r.MustGenerate()
-
-	// Setup server settings some options.
-	testServer, err := webserver.NewDefault(serverName, fmt.Sprintf("0.0.0.0:%d", port))
+	// port because of the possibility of collision. Port `0` picks a free one.
+	testServer, err := webserver.NewDefault(serverName, "127.0.0.1:0")
 	if err != nil {
 		logAndExit(err.Error())
 	}
@@ -169,10 +152,10 @@ func ExampleNewDefault() {
 		}
 	}()
 
-	// Ensures enough time for the server to be up, and ready - just for testing.
-	time.Sleep(1 * time.Second)
+	// Waits for the server to be up, and accepting connections.
+	<-testServer.Ready()
 
-	responseCode, body := callAndExpect(int(port), "/api/v1/", 200, "OK")
+	responseCode, body := callAndExpect(testServer.Addr(), "/api/v1/", 200, "OK")
 
 	fmt.Println(responseCode)
 	fmt.Println(body)
@@ -0,0 +1,250 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const defaultGroupShutdownTimeout = 30 * time.Second
+
+//////
+// Definitions.
+//////
+
+// GroupOption allows to define options for the Group.
+type GroupOption func(g *Group)
+
+// GroupError reports why a group stopped, and which servers failed to shut
+// down.
+type GroupError struct {
+	// Cause is the first fatal server error, which made the group shut down,
+	// if any.
+	Cause error
+
+	// Shutdown lists servers which failed to shut down, or timed out, if any.
+	Shutdown error
+}
+
+// Error interface implementation.
+func (e *GroupError) Error() string {
+	msgs := []string{}
+
+	if e.Cause != nil {
+		msgs = append(msgs, e.Cause.Error())
+	}
+
+	if e.Shutdown != nil {
+		msgs = append(msgs, e.Shutdown.Error())
+	}
+
+	return fmt.Sprintf("group failed: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap interface implementation.
+func (e *GroupError) Unwrap() error {
+	if e.Cause != nil {
+		return e.Cause
+	}
+
+	return e.Shutdown
+}
+
+// Group runs several servers with a shared lifecycle, e.g.: a public API, and
+// an internal admin server. They're started together, and once any of them
+// stops, all are shut down, in the order they were added.
+//
+// NOTE: Servers in a group shouldn't handle OS signals themselves, see
+// `WithGroupSignals`.
+type Group struct {
+	// ShutdownTimeout max duration to WAIT FOR ALL SERVERS to shut down,
+	// combined, default: 30s.
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+
+	// Servers in the group.
+	servers []IServer
+
+	// Result of `Shutdown`.
+	shutdownErr error
+
+	// Guarantees `Shutdown` runs only once.
+	shutdownOnce sync.Once
+
+	// OS signals which gracefully shut down the group, default: none.
+	signals []os.Signal
+}
+
+// Server result, once it stops running.
+type groupRunResult struct {
+	name string
+	err  error
+}
+
+//////
+// Helpers.
+//////
+
+// Returns the server name, or its address if it isn't a `Server`.
+func nameOf(srv IServer) string {
+	if s, ok := srv.(*Server); ok {
+		return s.Name
+	}
+
+	return srv.Addr()
+}
+
+//////
+// Group.
+//////
+
+// Start the group. It blocks until all servers stop. It's the same as calling
+// `Run` with a background context.
+func (g *Group) Start() error {
+	return g.Run(context.Background())
+}
+
+// Run all servers. It blocks until all of them stop. Any server stopping, e.g.:
+// failing to listen, canceling `ctx`, or receiving any of the signals set via
+// `WithGroupSignals` gracefully shuts down the group. The first fatal server
+// error, and shutdown errors are reported as a `GroupError`.
+func (g *Group) Run(ctx context.Context) error {
+	results := make(chan groupRunResult, len(g.servers))
+
+	for _, srv := range g.servers {
+		go func(srv IServer) {
+			// The group controls when servers shut down.
+			results <- groupRunResult{name: nameOf(srv), err: srv.Run(context.Background())}
+		}(srv)
+	}
+
+	// Only signals explicitly set via `WithGroupSignals` are handled.
+	osSignals := make(chan os.Signal, 1)
+
+	if len(g.signals) > 0 {
+		signal.Notify(osSignals, g.signals...)
+		defer signal.Stop(osSignals)
+	}
+
+	pending := len(g.servers)
+
+	var cause error
+
+	select {
+	case result := <-results:
+		pending--
+
+		if result.err != nil && !errors.Is(result.err, http.ErrServerClosed) {
+			cause = fmt.Errorf("%s: %w", result.name, result.err)
+		}
+	case <-ctx.Done():
+	case sig := <-osSignals:
+		// Let Go terminate the program if we get that signal again.
+		signal.Reset(sig)
+	}
+
+	shutdownErr := g.Shutdown(context.Background())
+
+	// Servers report the same shutdown errors, already collected.
+	for ; pending > 0; pending-- {
+		<-results
+	}
+
+	if cause != nil || shutdownErr != nil {
+		return &GroupError{Cause: cause, Shutdown: shutdownErr}
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down all servers, in the order they were added,
+// under the `ShutdownTimeout` budget - or `ctx`, whichever comes first. The
+// budget is passed down, bounding each server drain delay, in-flight requests,
+// and shutdown tasks. Servers not shut down in time are reported, and the next
+// ones are promptly stopped.
+// It's safe to be called multiple times, and concurrently, subsequent calls
+// return the result of the first one.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.shutdownOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(ctx, g.ShutdownTimeout)
+		defer cancel()
+
+		tasks := make([]ShutdownTask, 0, len(g.servers))
+
+		for _, srv := range g.servers {
+			tasks = append(tasks, ShutdownTask{Name: nameOf(srv), Func: srv.Shutdown})
+		}
+
+		g.shutdownErr = runShutdownTasks(ctx, false, tasks...)
+	})
+
+	return g.shutdownErr
+}
+
+//////
+// Options.
+//////
+
+// WithGroupSignals sets the OS signals which gracefully shut down the group,
+// e.g.: `os.Interrupt`, and `syscall.SIGTERM`. By default, no signal is
+// handled.
+func WithGroupSignals(signals ...os.Signal) GroupOption {
+	return func(g *Group) {
+		g.signals = signals
+	}
+}
+
+// WithGroupShutdownTimeout sets the combined budget to shut down all servers.
+func WithGroupShutdownTimeout(timeout time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ShutdownTimeout = timeout
+	}
+}
+
+//////
+// Factory.
+//////
+
+// NewGroup returns a group of `servers`, shut down in the given order.
+func NewGroup(servers []IServer, opts ...GroupOption) (*Group, error) {
+	if len(servers) == 0 {
+		return nil, customerror.NewMissingError("servers")
+	}
+
+	for _, srv := range servers {
+		if srv == nil {
+			return nil, customerror.NewInvalidError("servers, nil server")
+		}
+	}
+
+	g := &Group{
+		ShutdownTimeout: defaultGroupShutdownTimeout,
+
+		servers: servers,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if g.ShutdownTimeout <= 0 {
+		return nil, customerror.NewInvalidError("shutdown timeout, it needs to be positive")
+	}
+
+	return g, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+func TestGroup_Run(t *testing.T) {
+	var m sync.Mutex
+
+	drainingOrder := []string{}
+
+	newServer := func(name, address string) IServer {
+		t.Helper()
+
+		s, err := New(name, address, WithHandlers(handler.Liveness()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.OnStateChange(func(from, to State) {
+			if to == StateDraining {
+				m.Lock()
+				defer m.Unlock()
+
+				drainingOrder = append(drainingOrder, name)
+			}
+		})
+
+		return s
+	}
+
+	t.Run("Should work - shuts down all servers, in order", func(t *testing.T) {
+		public := newServer("public", "127.0.0.1:0")
+		admin := newServer("admin", "127.0.0.1:0")
+
+		g, err := NewGroup([]IServer{public, admin})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		groupErr := make(chan error, 1)
+
+		go func() {
+			groupErr <- g.Run(ctx)
+		}()
+
+		callAndExpect(t, waitReady(t, public), "liveness", 200, "")
+		callAndExpect(t, waitReady(t, admin), "liveness", 200, "")
+
+		cancel()
+
+		if err := <-groupErr; err != nil {
+			t.Fatal(err)
+		}
+
+		if public.State() != StateStopped || admin.State() != StateStopped {
+			t.Fatalf("Expected all servers to be stopped, got %s, %s", public.State(), admin.State())
+		}
+
+		if !reflect.DeepEqual(drainingOrder, []string{"public", "admin"}) {
+			t.Fatalf("Expected %v got %v", []string{"public", "admin"}, drainingOrder)
+		}
+	})
+
+	t.Run("Should work - shutdown tasks are bound by the group budget", func(t *testing.T) {
+		public := newServer("public", "127.0.0.1:0")
+
+		taskCtxDone := make(chan struct{})
+
+		public.OnShutdown("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+
+			close(taskCtxDone)
+
+			return ctx.Err()
+		})
+
+		g, err := NewGroup([]IServer{public}, WithGroupShutdownTimeout(200*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func() {
+			_ = g.Start()
+		}()
+
+		waitReady(t, public)
+
+		if err := g.Shutdown(context.Background()); err == nil {
+			t.Fatal("Expected the slow shutdown task to time out")
+		}
+
+		// Well before `ShutdownTaskTimeout`, 10s.
+		select {
+		case <-taskCtxDone:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected shutdown task to be bound by the group budget")
+		}
+	})
+
+	t.Run("Should fail - propagates the first fatal error", func(t *testing.T) {
+		inUse, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer inUse.Close()
+
+		public := newServer("public", "127.0.0.1:0")
+		admin := newServer("admin", inUse.Addr().String())
+
+		g, err := NewGroup([]IServer{public, admin})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = g.Start()
+
+		var groupErr *GroupError
+
+		if !errors.As(err, &groupErr) || groupErr.Cause == nil {
+			t.Fatalf("Expected fatal error to be reported, got %v", err)
+		}
+
+		if public.State() != StateStopped {
+			t.Fatalf("Expected %s got %s", StateStopped, public.State())
+		}
+	})
+}
+
+func TestNewGroup(t *testing.T) {
+	if _, err := NewGroup(nil); err == nil {
+		t.Fatal("Expected group without servers to fail")
+	}
+
+	if _, err := NewGroup([]IServer{nil}); err == nil {
+		t.Fatal("Expected group with nil server to fail")
+	}
+}
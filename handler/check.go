@@ -0,0 +1,217 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/metric"
+)
+
+//////
+// Definitions.
+//////
+
+// CheckFunc checks a dependency, e.g.: pings a database. It should honor
+// `ctx`, which is canceled once the check times out.
+type CheckFunc func(ctx context.Context) error
+
+// CheckThresholds are how many consecutive results flip the readiness state.
+type CheckThresholds struct {
+	// Success is how many consecutive successful checks make it ready,
+	// default: 1.
+	Success int
+
+	// Failure is how many consecutive failed checks make it not ready,
+	// default: 1.
+	Failure int
+}
+
+// CheckDeterminer is a readiness determiner which runs a check in the
+// background, every interval. Start, and stop it with the server, see
+// `webserver.WithChecks`, or `Start`, and `Stop`.
+type CheckDeterminer struct {
+	*ReadinessDeterminer
+
+	check      CheckFunc
+	interval   time.Duration
+	timeout    time.Duration
+	thresholds CheckThresholds
+
+	// Consecutive results, only accessed by the running check loop.
+	failures  int
+	successes int
+
+	cancel  context.CancelFunc
+	done    chan struct{}
+	m       sync.Mutex
+	metrics *metric.Map
+}
+
+//////
+// CheckDeterminer.
+//////
+
+// Runs the check once, updating the readiness state, and metrics.
+func (c *CheckDeterminer) run(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	err := c.check(ctx)
+
+	latency := time.Since(start)
+
+	// Stopped, the result is meaningless.
+	if parent.Err() != nil {
+		return
+	}
+
+	c.metrics.Add("runs", 1)
+	c.metrics.Get("latency_seconds").(*metric.Float).Set(latency.Seconds())
+
+	details := map[string]string{
+		"last_check": start.UTC().Format(time.RFC3339),
+		"latency":    latency.String(),
+	}
+
+	if err != nil {
+		c.metrics.Add("failures", 1)
+
+		c.failures++
+		c.successes = 0
+
+		details["consecutive_failures"] = fmt.Sprint(c.failures)
+
+		c.SetDetails(details)
+
+		// Keeps the current state until the threshold is reached. Overrides
+		// don't count, e.g.: forcing it ready.
+		if c.failures >= c.thresholds.Failure || !c.isReady() {
+			c.SetReadinessWithReason(false, err.Error())
+		}
+
+		return
+	}
+
+	c.successes++
+	c.failures = 0
+
+	c.SetDetails(details)
+
+	if c.successes >= c.thresholds.Success || c.isReady() {
+		c.SetReadiness(true)
+	}
+}
+
+// Start runs the check right away, then every interval, in the background,
+// until `Stop` is called, or `ctx` is done. Starting a running check does
+// nothing.
+func (c *CheckDeterminer) Start(ctx context.Context) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.cancel != nil {
+		return
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.run(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}(c.done)
+}
+
+// Stop stops running the check, waiting the running one, if any, to finish.
+func (c *CheckDeterminer) Stop() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.cancel == nil {
+		return
+	}
+
+	c.cancel()
+
+	<-c.done
+
+	c.cancel = nil
+}
+
+// Metrics returns the check metrics: `runs`, `failures`, and the last check
+// `latency_seconds`.
+func (c *CheckDeterminer) Metrics() *metric.Map {
+	return c.metrics
+}
+
+//////
+// Factory.
+//////
+
+// NewCheckDeterminer returns a readiness determiner named `name`, which runs
+// `check` every `interval`, timing out after `timeout`. It's not ready until
+// `thresholds.Success` consecutive checks succeed, and then not ready once
+// `thresholds.Failure` consecutive checks fail, reporting the error as the
+// reason.
+func NewCheckDeterminer(
+	name string,
+	check CheckFunc,
+	interval, timeout time.Duration,
+	thresholds CheckThresholds,
+) (*CheckDeterminer, error) {
+	if check == nil {
+		return nil, customerror.NewMissingError("check, " + name)
+	}
+
+	if interval <= 0 || timeout <= 0 {
+		return nil, customerror.NewInvalidError("check " + name + ", interval, and timeout need to be positive")
+	}
+
+	if thresholds.Success < 0 || thresholds.Failure < 0 {
+		return nil, customerror.NewInvalidError("check " + name + ", thresholds can't be negative")
+	}
+
+	if thresholds.Success == 0 {
+		thresholds.Success = 1
+	}
+
+	if thresholds.Failure == 0 {
+		thresholds.Failure = 1
+	}
+
+	metrics := new(metric.Map).Init()
+	metrics.Set("failures", new(metric.Int))
+	metrics.Set("latency_seconds", new(metric.Float))
+	metrics.Set("runs", new(metric.Int))
+
+	return &CheckDeterminer{
+		ReadinessDeterminer: NewReadinessDeterminer(name),
+
+		check:      check,
+		interval:   interval,
+		timeout:    timeout,
+		thresholds: thresholds,
+		metrics:    metrics,
+	}, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckDeterminer_run(t *testing.T) {
+	var checkErr error
+
+	c, err := NewCheckDeterminer("db", func(ctx context.Context) error {
+		return checkErr
+	}, time.Second, time.Second, CheckThresholds{Success: 2, Failure: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errDown := errors.New("connection refused")
+
+	steps := []struct {
+		name       string
+		err        error
+		override   *bool
+		wantReady  bool
+		wantReason string
+	}{
+		{name: "Should stay not ready - one success", wantReady: false},
+		{name: "Should be ready - two successes", wantReady: true},
+		{name: "Should stay ready - one failure", err: errDown, wantReady: true},
+		{name: "Should not be ready - two failures", err: errDown, wantReady: false, wantReason: errDown.Error()},
+		{name: "Should stay not ready - one success", wantReady: false, wantReason: errDown.Error()},
+		{name: "Should recover - two successes", wantReady: true},
+		{
+			name:      "Should stay ready - one failure, overridden as not ready",
+			err:       errDown,
+			override:  new(bool),
+			wantReady: true,
+		},
+		{name: "Should not be ready - two failures, overridden", err: errDown, wantReady: false, wantReason: errDown.Error()},
+	}
+
+	for _, step := range steps {
+		checkErr = step.err
+
+		if step.override != nil {
+			c.SetOverride(*step.override)
+		}
+
+		c.run(context.Background())
+
+		if c.isReady() != step.wantReady {
+			t.Fatalf("%s: expected ready %v", step.name, step.wantReady)
+		}
+
+		if c.GetReason() != step.wantReason {
+			t.Fatalf("%s: expected reason %q got %q", step.name, step.wantReason, c.GetReason())
+		}
+	}
+
+	if runs := c.Metrics().Get("runs").String(); runs != "8" {
+		t.Fatalf("Expected 8 runs got %s", runs)
+	}
+
+	if failures := c.Metrics().Get("failures").String(); failures != "4" {
+		t.Fatalf("Expected 4 failures got %s", failures)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/validation"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const (
+	// MIMEProblemJSON is the RFC 7807 problem details media type.
+	MIMEProblemJSON = "application/problem+json"
+
+	// RequestIDHeader is the request ID header.
+	RequestIDHeader = "X-Request-ID"
+)
+
+//////
+// Definitions.
+//////
+
+// ErrorResponse is the JSON error body.
+type ErrorResponse struct {
+	// Code is the error custom code, if any, e.g.: E1010.
+	Code string `json:"code,omitempty"`
+
+	// Fields which failed validation, if any.
+	Fields []validation.FieldError `json:"fields,omitempty"`
+
+	// Message is the human readable message.
+	Message string `json:"message"`
+
+	// RequestID identifies the request, if any.
+	RequestID string `json:"request_id,omitempty"`
+
+	// StatusCode is the HTTP status code.
+	StatusCode int `json:"status_code"`
+}
+
+// ProblemResponse is the RFC 7807 problem details error body.
+type ProblemResponse struct {
+	// Type identifies the problem type.
+	Type string `json:"type"`
+
+	// Title is the HTTP status text.
+	Title string `json:"title"`
+
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+
+	// Detail is the human readable message.
+	Detail string `json:"detail"`
+
+	// Instance is the request path.
+	Instance string `json:"instance"`
+
+	// Code is the error custom code, if any, e.g.: E1010.
+	Code string `json:"code,omitempty"`
+
+	// Fields which failed validation, if any.
+	Fields []validation.FieldError `json:"fields,omitempty"`
+
+	// RequestID identifies the request, if any.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+//////
+// Helpers.
+//////
+
+// RequestID returns the request ID, see `RequestIDHeader`.
+func RequestID(r *http.Request) string {
+	return r.Header.Get(RequestIDHeader)
+}
+
+// WriteError writes `err` as a JSON body, or a RFC 7807 problem details body if
+// accepted by the client. The status code, code, and message are taken from
+// `customerror`, otherwise it's `500`, and the status text - not leaking
+// internal details. Fields which failed validation, if any, are listed, see
+// `validation.ValidationError`.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	statusCode := http.StatusInternalServerError
+	message := http.StatusText(statusCode)
+	code := ""
+
+	var cE *customerror.CustomError
+
+	if errors.As(err, &cE) {
+		if cE.StatusCode != 0 {
+			statusCode = cE.StatusCode
+		}
+
+		message = cE.Message
+		code = cE.Code
+	}
+
+	var fields []validation.FieldError
+
+	var vE *validation.ValidationError
+
+	if errors.As(err, &vE) {
+		fields = vE.Fields
+
+		// Not wrapped, e.g.: by `validation.ValidateStruct`.
+		if cE == nil {
+			statusCode = http.StatusBadRequest
+			message = "invalid data"
+		}
+	}
+
+	var body interface{} = ErrorResponse{
+		Code:       code,
+		Fields:     fields,
+		Message:    message,
+		RequestID:  RequestID(r),
+		StatusCode: statusCode,
+	}
+
+	contentType := "application/json; charset=utf-8"
+
+	if strings.Contains(r.Header.Get("Accept"), MIMEProblemJSON) {
+		contentType = MIMEProblemJSON
+
+		body = ProblemResponse{
+			Type:      "about:blank",
+			Title:     http.StatusText(statusCode),
+			Status:    statusCode,
+			Detail:    message,
+			Instance:  r.URL.Path,
+			Code:      code,
+			Fields:    fields,
+			RequestID: RequestID(r),
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	w.WriteHeader(statusCode)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -6,8 +6,9 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
-	"github.com/saucelabs/webserver/internal/validation"
+	"github.com/saucelabs/webserver/validation"
 )
 
 //////
@@ -24,6 +25,15 @@ type Handler struct {
 
 	// Path to run the `Handler`.
 	Path string `json:"path" validate:"required"`
+
+	// Timeout overrides the server request timeout for this handler,
+	// default: 0, the server one.
+	Timeout time.Duration `json:"timeout" validate:"gte=0"`
+
+	// Streaming exempts this handler from request timeouts, and response
+	// buffering, e.g.: SSE, long downloads, websockets, and `http.Flusher`.
+	// Read, and write deadlines are lifted too, where supported.
+	Streaming bool `json:"streaming"`
 }
 
 //////
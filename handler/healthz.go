@@ -0,0 +1,180 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//////
+// Helpers.
+//////
+
+// Kubernetes-style health handler for `probe`, e.g.: `readyz`. It replies `200`,
+// and "ok" if all checks - `statuses` - pass, otherwise `503`, and one line
+// per check, e.g.: `[-]db failed: connection refused`. Checks can be skipped
+// via the `exclude` query param, e.g.: `?exclude=db`. The `verbose` query
+// param lists the checks even if all pass, e.g.: `[+]db ok`.
+func healthz(probe string, statuses func(r *http.Request) []ReadinessStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		excluded := map[string]bool{}
+
+		for _, name := range r.URL.Query()["exclude"] {
+			excluded[name] = true
+		}
+
+		var checks strings.Builder
+
+		passed := true
+
+		for _, status := range statuses(r) {
+			switch {
+			case excluded[status.Name]:
+				delete(excluded, status.Name)
+
+				fmt.Fprintf(&checks, "[+]%s excluded: ok\n", status.Name)
+			case status.Ready:
+				fmt.Fprintf(&checks, "[+]%s ok\n", status.Name)
+			default:
+				passed = false
+
+				reason := status.Reason
+
+				if reason == "" {
+					reason = "not ready"
+				}
+
+				fmt.Fprintf(&checks, "[-]%s failed: %s\n", status.Name, reason)
+			}
+		}
+
+		if len(excluded) > 0 {
+			names := []string{}
+
+			for name := range excluded {
+				names = append(names, fmt.Sprintf("%q", name))
+			}
+
+			sort.Strings(names)
+
+			fmt.Fprintf(&checks, "warn: some health checks cannot be excluded: no matches for %s\n", strings.Join(names, ", "))
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if !passed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			fmt.Fprintf(w, "%s%s check failed\n", checks.String(), probe)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Query().Has("verbose") {
+			fmt.Fprintf(w, "%s%s check passed\n", checks.String(), probe)
+
+			return
+		}
+
+		fmt.Fprint(w, "ok")
+	}
+}
+
+// Returns the status of every determiner.
+func statusesOf(readinessStates []*ReadinessDeterminer) []ReadinessStatus {
+	statuses := make([]ReadinessStatus, 0, len(readinessStates))
+
+	for _, readinessState := range readinessStates {
+		statuses = append(statuses, readinessState.Status())
+	}
+
+	return statuses
+}
+
+//////
+// Handlers.
+//////
+
+// Livez is the Kubernetes-style liveness probe. The server is alive if it
+// replies, so its only check, `ping`, always passes. See `healthz`.
+func Livez() Handler {
+	return Handler{
+		Handler: healthz("livez", func(r *http.Request) []ReadinessStatus {
+			return []ReadinessStatus{{Name: "ping", Ready: true}}
+		}),
+		Method: http.MethodGet,
+		Path:   "/livez",
+	}
+}
+
+// Readyz is the Kubernetes-style readiness probe. It passes if ALL
+// determiners are ready. Output is in the Kubernetes format, e.g.:
+// `[+]db ok`, see `healthz`.
+func Readyz(readinessStates ...*ReadinessDeterminer) Handler {
+	return Handler{
+		Handler: healthz("readyz", func(r *http.Request) []ReadinessStatus {
+			return statusesOf(readinessStates)
+		}),
+		Method: http.MethodGet,
+		Path:   "/readyz",
+	}
+}
+
+// ReadyzCheck is the Kubernetes-style readiness probe for a single
+// determiner, by name, e.g.: `/readyz/db`. It replies `404` if none matches.
+func ReadyzCheck(readinessStates ...*ReadinessDeterminer) Handler {
+	return Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := mux.Vars(r)["name"]
+
+			for _, readinessState := range readinessStates {
+				if readinessState.GetName() != name {
+					continue
+				}
+
+				healthz("readyz", func(r *http.Request) []ReadinessStatus {
+					return []ReadinessStatus{readinessState.Status()}
+				})(w, r)
+
+				return
+			}
+
+			http.Error(w, fmt.Sprintf("no such readiness check: %q", name), http.StatusNotFound)
+		}),
+		Method: http.MethodGet,
+		Path:   "/readyz/{name}",
+	}
+}
+
+// Startupz is the Kubernetes-style startup probe. It fails until ALL
+// determiners were ready at least once, then it passes from there on, e.g.:
+// warming caches, or running migrations. See `healthz`.
+func Startupz(readinessStates ...*ReadinessDeterminer) Handler {
+	return Handler{
+		Handler: healthz("startupz", func(r *http.Request) []ReadinessStatus {
+			statuses := make([]ReadinessStatus, 0, len(readinessStates))
+
+			for _, readinessState := range readinessStates {
+				status := readinessState.Status()
+
+				status.Ready = readinessState.hasBeenReady()
+
+				statuses = append(statuses, status)
+			}
+
+			return statuses
+		}),
+		Method: http.MethodGet,
+		Path:   "/startupz",
+	}
+}
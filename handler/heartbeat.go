@@ -0,0 +1,136 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Definitions.
+//////
+
+// HeartbeatDeterminer is a readiness determiner which is ready while its
+// owner, e.g.: a background worker, calls `Beat` at least every TTL. Once
+// heartbeats stop, it's not ready, reporting for how long it's stale as the
+// reason, e.g.: "stale for 3s". Register it as any other determiner, e.g.:
+// `webserver.WithReadiness(heartbeat.ReadinessDeterminer)`.
+type HeartbeatDeterminer struct {
+	*ReadinessDeterminer
+
+	lastBeat time.Time
+	stopped  bool
+	timer    *time.Timer
+	ttl      time.Duration
+	m        sync.Mutex
+}
+
+//////
+// HeartbeatDeterminer.
+//////
+
+// Returns how often the stale reason is updated: every second, or every TTL
+// if shorter.
+func (h *HeartbeatDeterminer) refreshInterval() time.Duration {
+	if h.ttl < time.Second {
+		return h.ttl
+	}
+
+	return time.Second
+}
+
+// Formats for how long it's stale: in seconds, or milliseconds if under a
+// second, e.g.: a short TTL.
+func staleReason(stale time.Duration) string {
+	if stale < time.Second {
+		return "stale for " + stale.Truncate(time.Millisecond).String()
+	}
+
+	return "stale for " + stale.Round(time.Second).String()
+}
+
+// Flips to not ready once stale, then keeps the reason current, re-arming the
+// timer until the next `Beat`, or `Stop`.
+func (h *HeartbeatDeterminer) expire() {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	stale := time.Since(h.lastBeat)
+
+	// Stopped, or beat in the meantime, which already re-armed the timer.
+	if h.stopped || stale < h.ttl {
+		return
+	}
+
+	h.SetReadinessWithReason(false, staleReason(stale))
+
+	h.timer.Reset(h.refreshInterval())
+}
+
+// Beat signals the owner is alive, making it ready for another TTL.
+func (h *HeartbeatDeterminer) Beat() {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.lastBeat = time.Now()
+	h.stopped = false
+
+	if h.timer == nil {
+		h.timer = time.AfterFunc(h.ttl, h.expire)
+	} else {
+		h.timer.Reset(h.ttl)
+	}
+
+	h.SetDetails(map[string]string{"last_beat": h.lastBeat.UTC().Format(time.RFC3339)})
+
+	h.SetReadiness(true)
+}
+
+// GetLastBeat returns when `Beat` was last called, zero if never.
+func (h *HeartbeatDeterminer) GetLastBeat() time.Time {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	return h.lastBeat
+}
+
+// Stop releases the expiration timer, e.g.: once the owner is done. The
+// readiness state is kept as is. Calling `Beat` starts it again.
+func (h *HeartbeatDeterminer) Stop() {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.stopped = true
+
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+}
+
+//////
+// Factory.
+//////
+
+// NewHeartbeatDeterminer returns a readiness determiner named `name`, which is
+// not ready until the first `Beat`, and not ready once `ttl` elapses without
+// one.
+func NewHeartbeatDeterminer(name string, ttl time.Duration) (*HeartbeatDeterminer, error) {
+	if ttl <= 0 {
+		return nil, customerror.NewInvalidError("heartbeat " + name + ", ttl needs to be positive")
+	}
+
+	h := &HeartbeatDeterminer{
+		ReadinessDeterminer: NewReadinessDeterminer(name),
+
+		ttl: ttl,
+	}
+
+	h.SetReadinessWithReason(false, "no heartbeat yet")
+
+	return h, nil
+}
@@ -5,17 +5,57 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // ReadinessDeterminer definition. It determines if `name` is ready.
 type ReadinessDeterminer struct {
-	name  string
-	ready bool
-	m     sync.Mutex
+	details        map[string]string
+	lastTransition time.Time
+	name           string
+	override       *bool
+	ready          bool
+	reason         string
+	wasReady       bool
+	m              sync.Mutex
+}
+
+// ReadinessStatus is a snapshot of a readiness determiner.
+type ReadinessStatus struct {
+	// Name of the determiner.
+	Name string `json:"name"`
+
+	// Ready is the readiness state, the override if overridden.
+	Ready bool `json:"ready"`
+
+	// Overridden indicates the readiness state is forced, see `SetOverride`.
+	Overridden bool `json:"overridden,omitempty"`
+
+	// Reason explains the readiness state, if any, e.g.: "connection
+	// refused".
+	Reason string `json:"reason,omitempty"`
+
+	// LastTransition is when the readiness state last changed.
+	LastTransition time.Time `json:"last_transition"`
+
+	// Details are optional, additional information, e.g.: the upstream
+	// address.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// ReadinessReport is the readiness of every determiner.
+type ReadinessReport struct {
+	// Ready is only true if ALL determiners are ready.
+	Ready bool `json:"ready"`
+
+	// Determiners status, in order.
+	Determiners []ReadinessStatus `json:"determiners"`
 }
 
 // Set state name.
@@ -34,28 +74,210 @@ func (t *ReadinessDeterminer) GetName() string {
 	return t.name
 }
 
-// Set readiness state.
+// Set readiness state. The reason is cleared.
 func (t *ReadinessDeterminer) SetReadiness(v bool) {
+	t.SetReadinessWithReason(v, "")
+}
+
+// SetReadinessWithReason sets the readiness state, and explains it, e.g.:
+// `SetReadinessWithReason(false, "connection refused")`.
+func (t *ReadinessDeterminer) SetReadinessWithReason(v bool, reason string) {
 	t.m.Lock()
 	defer t.m.Unlock()
 
+	before := t.readyLocked()
+
 	t.ready = v
+	t.reason = reason
+
+	t.transitionLocked(before)
 }
 
-// Get readiness state.
-func (t *ReadinessDeterminer) GetReadiness() bool {
+// Returns the readiness state, the override if overridden.
+func (t *ReadinessDeterminer) readyLocked() bool {
+	if t.override != nil {
+		return *t.override
+	}
+
+	return t.ready
+}
+
+// Records the transition time if the readiness state changed from `before`,
+// and whether it was ever ready, see `Startupz`.
+func (t *ReadinessDeterminer) transitionLocked(before bool) {
+	if t.readyLocked() != before {
+		t.lastTransition = time.Now()
+	}
+
+	if t.readyLocked() {
+		t.wasReady = true
+	}
+}
+
+// Returns the readiness state set via `SetReadiness`, regardless of any
+// override.
+func (t *ReadinessDeterminer) isReady() bool {
 	t.m.Lock()
 	defer t.m.Unlock()
 
 	return t.ready
 }
 
+// Returns whether the readiness state was ever ready, override included.
+func (t *ReadinessDeterminer) hasBeenReady() bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.wasReady
+}
+
+// Get readiness state. If overridden, it's the override.
+func (t *ReadinessDeterminer) GetReadiness() bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.readyLocked()
+}
+
+// GetReason returns the readiness state reason, if any.
+func (t *ReadinessDeterminer) GetReason() string {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.reason
+}
+
+// GetLastTransition returns when the readiness state last changed.
+func (t *ReadinessDeterminer) GetLastTransition() time.Time {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.lastTransition
+}
+
+// SetDetails sets optional, additional information, e.g.: the upstream
+// address. It's reported by the readiness handler.
+func (t *ReadinessDeterminer) SetDetails(details map[string]string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.details = make(map[string]string, len(details))
+
+	for k, v := range details {
+		t.details[k] = v
+	}
+}
+
+// SetOverride forces the readiness state to `v`, regardless of
+// `SetReadiness`, e.g.: taking an instance out of rotation.
+func (t *ReadinessDeterminer) SetOverride(v bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	before := t.readyLocked()
+
+	t.override = &v
+
+	t.transitionLocked(before)
+}
+
+// ClearOverride restores the readiness state set via `SetReadiness`.
+func (t *ReadinessDeterminer) ClearOverride() {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	before := t.readyLocked()
+
+	t.override = nil
+
+	t.transitionLocked(before)
+}
+
+// Status returns a consistent snapshot of the determiner.
+func (t *ReadinessDeterminer) Status() ReadinessStatus {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	status := ReadinessStatus{
+		Name:           t.name,
+		Ready:          t.readyLocked(),
+		Overridden:     t.override != nil,
+		Reason:         t.reason,
+		LastTransition: t.lastTransition,
+	}
+
+	if len(t.details) > 0 {
+		status.Details = make(map[string]string, len(t.details))
+
+		for k, v := range t.details {
+			status.Details[k] = v
+		}
+	}
+
+	return status
+}
+
 // NewReadinessDeterminer is the Readiness factory.
 func NewReadinessDeterminer(name string) *ReadinessDeterminer {
 	return &ReadinessDeterminer{
-		name:  name,
-		ready: false,
-		m:     sync.Mutex{},
+		lastTransition: time.Now(),
+		name:           name,
+		ready:          false,
+		m:              sync.Mutex{},
+	}
+}
+
+// NewReadinessReport returns the readiness of every determiner.
+func NewReadinessReport(readinessStates ...*ReadinessDeterminer) ReadinessReport {
+	report := ReadinessReport{
+		Ready:       true,
+		Determiners: make([]ReadinessStatus, 0, len(readinessStates)),
+	}
+
+	for _, readinessState := range readinessStates {
+		status := readinessState.Status()
+
+		// If any state isn't ready, server isn't ready.
+		if !status.Ready {
+			report.Ready = false
+		}
+
+		report.Determiners = append(report.Determiners, status)
+	}
+
+	return report
+}
+
+// Writes `report` as text, one determiner per line, followed by its details.
+func writeReadinessText(w http.ResponseWriter, report ReadinessReport) {
+	for _, status := range report.Determiners {
+		state := "ready"
+
+		if !status.Ready {
+			state = "not ready"
+		}
+
+		if status.Overridden {
+			state += " (overridden)"
+		}
+
+		if status.Reason != "" {
+			state += ", " + status.Reason
+		}
+
+		fmt.Fprintf(w, "%s: %s, since %s\n", status.Name, state, status.LastTransition.UTC().Format(time.RFC3339))
+
+		keys := make([]string, 0, len(status.Details))
+
+		for k := range status.Details {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", k, status.Details[k])
+		}
 	}
 }
 
@@ -64,21 +286,50 @@ func NewReadinessDeterminer(name string) *ReadinessDeterminer {
 // ready, otherwise sends `503`, "Service Unavailable", and the error. Multiple
 // readinesses determiners can be passed. In this case, only if ALL are ready,
 // the server will be considered ready.
+//
+// A report of every determiner - readiness state, reason, last transition,
+// and details - is sent as JSON if accepted by the client (`Accept:
+// application/json`), or as text if the `verbose` query param is set. See
+// `ReadinessReport`.
 func Readiness(readinessStates ...*ReadinessDeterminer) Handler {
 	return Handler{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			readinessStateFinalState := true
-			readinessesNames := []string{}
-
-			for _, readinessState := range readinessStates {
-				// If any state isn't ready, server isn't ready.
-				if !readinessState.GetReadiness() {
-					readinessStateFinalState = false
-					readinessesNames = append(readinessesNames, readinessState.GetName())
-				}
+			report := NewReadinessReport(readinessStates...)
+
+			statusCode := http.StatusOK
+
+			if !report.Ready {
+				statusCode = http.StatusServiceUnavailable
+			}
+
+			switch {
+			case strings.Contains(r.Header.Get("Accept"), "application/json"):
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+				w.WriteHeader(statusCode)
+
+				_ = json.NewEncoder(w).Encode(report)
+
+				return
+			case r.URL.Query().Has("verbose"):
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+				w.WriteHeader(statusCode)
+
+				writeReadinessText(w, report)
+
+				return
 			}
 
-			if !readinessStateFinalState {
+			if !report.Ready {
+				readinessesNames := []string{}
+
+				for _, status := range report.Determiners {
+					if !status.Ready {
+						readinessesNames = append(readinessesNames, status.Name)
+					}
+				}
+
 				http.Error(
 					w,
 					fmt.Sprintf("server isn't ready. %s failed readiness", strings.Join(readinessesNames, ", ")),
@@ -5,42 +5,418 @@
 package handler
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
 )
 
-// Stop allows the server to be remotely, and gracefully stopped. Optionally set
-// the `hard` query param to `true` to immediately kill the server.
-func Stop() Handler {
+//////
+// Consts, and vars.
+//////
+
+const (
+	// StopDenied means the stop request wasn't authorized.
+	StopDenied StopStage = "denied"
+
+	// StopAccepted means shutdown started.
+	StopAccepted StopStage = "accepted"
+
+	// StopFinished means shutdown finished, see `StopEvent.Err`.
+	StopFinished StopStage = "finished"
+)
+
+var (
+	// ErrStopUnauthorized is returned when the stop request isn't
+	// authenticated, e.g.: missing, or wrong token.
+	ErrStopUnauthorized = customerror.New(
+		"not authorized to stop the server",
+		customerror.WithStatusCode(http.StatusUnauthorized),
+	)
+
+	// ErrStopForbidden is returned when the stop request isn't allowed, e.g.:
+	// not from localhost, or an unknown client certificate identity.
+	ErrStopForbidden = customerror.New(
+		"not allowed to stop the server",
+		customerror.WithStatusCode(http.StatusForbidden),
+	)
+
+	// ErrStopInProgress is returned when shutdown already started.
+	ErrStopInProgress = customerror.New(
+		"server is already shutting down",
+		customerror.WithStatusCode(http.StatusConflict),
+	)
+)
+
+//////
+// Definitions.
+//////
+
+// Shutdowner is anything which can be gracefully shut down, e.g.: a server.
+type Shutdowner interface {
+	// Shutdown gracefully shuts down.
+	Shutdown(ctx context.Context) error
+}
+
+// Reports whether shutdown started, e.g.: triggered by an OS signal.
+type shuttingDowner interface {
+	ShuttingDown() bool
+}
+
+// StopStage is the stage of a stop request, see `StopEvent`.
+type StopStage string
+
+// StopEvent audits a stop request.
+type StopEvent struct {
+	// Stage of the stop request.
+	Stage StopStage
+
+	// Who requested it: the client certificate identity, `token`, or the
+	// remote address.
+	Who string
+
+	// RemoteAddr is the client address.
+	RemoteAddr string
+
+	// RequestID identifies the request.
+	RequestID string
+
+	// Timeout is the requested drain timeout, if any, see `Stop`.
+	Timeout time.Duration
+
+	// Err is why it was denied, or the shutdown result, if any.
+	Err error
+}
+
+// StopResponse is the JSON body of an accepted, or finished stop request.
+type StopResponse struct {
+	// Message is the human readable message.
+	Message string `json:"message"`
+
+	// RequestID identifies the request, if any.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Timeout is the requested drain timeout, if any.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Authorizes a stop request, returning who requested it.
+type stopAuthorizer func(r *http.Request) (string, error)
+
+// Stop handler settings.
+type stopSettings struct {
+	audit         func(e StopEvent)
+	authenticated bool
+	authorizers   []stopAuthorizer
+	wait          bool
+}
+
+// StopOption allows to define options for `Stop`.
+type StopOption func(s *stopSettings)
+
+//////
+// Options.
+//////
+
+// WithStopAudit calls `fn` for every stop request: denied, accepted, and once
+// shutdown finishes, e.g.: logging who stopped the server.
+func WithStopAudit(fn func(e StopEvent)) StopOption {
+	return func(s *stopSettings) {
+		s.audit = fn
+	}
+}
+
+// WithStopToken authorizes requests bearing `token`, e.g.:
+// `Authorization: Bearer {token}`.
+func WithStopToken(token string) StopOption {
+	return func(s *stopSettings) {
+		s.authenticated = true
+
+		s.authorizers = append(s.authorizers, func(r *http.Request) (string, error) {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				return "", ErrStopUnauthorized
+			}
+
+			return "token", nil
+		})
+	}
+}
+
+// WithStopIdentities authorizes requests presenting a client certificate
+// (mutual TLS) of one of `identities`, see `PeerIdentity`.
+func WithStopIdentities(identities ...string) StopOption {
+	return func(s *stopSettings) {
+		s.authenticated = true
+
+		s.authorizers = append(s.authorizers, func(r *http.Request) (string, error) {
+			identity := PeerIdentity(r)
+
+			if identity == "" {
+				return "", ErrStopUnauthorized
+			}
+
+			for _, allowed := range identities {
+				if identity == allowed {
+					return identity, nil
+				}
+			}
+
+			return "", ErrStopForbidden
+		})
+	}
+}
+
+// WithStopLocalhost authorizes requests from the loopback interface, or a Unix
+// domain socket. It's the default if no other authorization is set.
+//
+// NOTE: Behind a reverse proxy on the same host, every request comes from the
+// loopback interface, so anyone reaching the proxy could stop the server. Only
+// rely on it if `Stop` isn't reachable via a proxy, e.g.: a dedicated admin
+// listener, otherwise see `WithStopToken`, and `WithStopIdentities`.
+func WithStopLocalhost() StopOption {
+	return func(s *stopSettings) {
+		s.authorizers = append(s.authorizers, authorizeLocalhost)
+	}
+}
+
+// WithStopWait waits for shutdown to finish, replying `200`, or the shutdown
+// error. Only set it if `Stop` is served by a different HTTP server than the
+// one being shut down, e.g.: an admin listener - shutdown waits for in-flight
+// requests, so a server can't wait for its own. The handler is then exempt
+// from request timeouts, as shutdown may take longer, see `Handler.Streaming`.
+func WithStopWait() StopOption {
+	return func(s *stopSettings) {
+		s.wait = true
+	}
+}
+
+//////
+// Helpers.
+//////
+
+// Authorizes requests from the loopback interface, or a Unix domain socket.
+func authorizeLocalhost(r *http.Request) (string, error) {
+	// Unix domain sockets have no remote address.
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return "localhost", nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return "", ErrStopForbidden
+	}
+
+	return r.RemoteAddr, nil
+}
+
+// Authorizes `r` if any of the authorizers does. The most relevant error is
+// returned: unauthorized (e.g.: wrong token) over forbidden.
+func (s *stopSettings) authorize(r *http.Request) (string, error) {
+	var authErr error
+
+	for _, authorizer := range s.authorizers {
+		who, err := authorizer(r)
+		if err == nil {
+			return who, nil
+		}
+
+		if authErr == nil || errors.Is(err, ErrStopUnauthorized) {
+			authErr = err
+		}
+	}
+
+	return "", authErr
+}
+
+// Writes the JSON stop response.
+func writeStopResponse(w http.ResponseWriter, status int, resp StopResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// StopAuthenticated reports whether `opts` authenticate stop requests, i.e.:
+// by a token, or a client certificate identity, rather than only trusting
+// where they come from. See `WithStopToken`, and `WithStopIdentities`.
+func StopAuthenticated(opts ...StopOption) bool {
+	settings := &stopSettings{}
+
+	for _, opt := range opts {
+		opt(settings)
+	}
+
+	return settings.authenticated
+}
+
+// Parses the `timeout` query param, the drain timeout, if `set`.
+func stopTimeout(r *http.Request) (timeout time.Duration, set bool, err error) {
+	param := r.URL.Query().Get("timeout")
+
+	if param == "" {
+		return 0, false, nil
+	}
+
+	timeout, err = time.ParseDuration(param)
+	if err != nil || timeout < 0 {
+		return 0, false, customerror.NewInvalidError(fmt.Sprintf("timeout, %q isn't a positive duration, e.g.: 10s", param))
+	}
+
+	return timeout, true, nil
+}
+
+// Stop allows the server to be remotely, and gracefully stopped via `POST`.
+// Requests need to be authorized, by default only from localhost, see
+// `WithStopToken`, `WithStopIdentities`, and `WithStopLocalhost` - any of
+// them authorizes it. Optionally set the `timeout` query param to limit how
+// long in-flight requests are waited, e.g.: `10s`, `0s` stops immediately.
+//
+// Shutdown waits for in-flight requests, including this one, so it replies
+// `202` once shutdown starts, or `409` if it already started. The client
+// doesn't get the shutdown result, only the audit does, see `WithStopAudit`.
+// If `Stop` is served by a different HTTP server than the one being shut down,
+// e.g.: an admin listener, set `WithStopWait` to reply the result instead.
+func Stop(s Shutdowner, opts ...StopOption) Handler {
+	settings := &stopSettings{audit: func(StopEvent) {}}
+
+	for _, opt := range opts {
+		opt(settings)
+	}
+
+	if len(settings.authorizers) == 0 {
+		settings.authorizers = []stopAuthorizer{authorizeLocalhost}
+	}
+
+	var (
+		m        sync.Mutex
+		stopping bool
+	)
+
 	return Handler{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			queryParams := r.URL.Query()
+			e := StopEvent{RemoteAddr: r.RemoteAddr, RequestID: RequestID(r)}
 
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			who, err := settings.authorize(r)
+			if err != nil {
+				e.Stage = StopDenied
+				e.Err = err
 
-			w.WriteHeader(http.StatusOK)
+				settings.audit(e)
 
-			fmt.Fprintln(w, http.StatusText(http.StatusOK))
+				if errors.Is(err, ErrStopUnauthorized) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
 
-			sig := os.Interrupt
+				WriteError(w, r, err)
 
-			if queryParams.Get("hard") == "true" {
-				sig = os.Kill
+				return
 			}
 
-			p, err := os.FindProcess(os.Getpid())
+			e.Who = who
+
+			timeout, hasTimeout, err := stopTimeout(r)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				e.Stage = StopDenied
+				e.Err = err
+
+				settings.audit(e)
+
+				WriteError(w, r, err)
 
 				return
 			}
 
-			if err := p.Signal(sig); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			e.Timeout = timeout
+
+			m.Lock()
+
+			inProgress := stopping
+
+			if sd, ok := s.(shuttingDowner); ok && sd.ShuttingDown() {
+				inProgress = true
+			}
+
+			stopping = true
+
+			m.Unlock()
+
+			if inProgress {
+				WriteError(w, r, ErrStopInProgress)
+
+				return
 			}
+
+			e.Stage = StopAccepted
+
+			settings.audit(e)
+
+			resp := StopResponse{Message: "shutting down", RequestID: e.RequestID}
+
+			if hasTimeout {
+				resp.Timeout = timeout.String()
+			}
+
+			shutdown := func() error {
+				// NOTE: Request context is canceled once the request is done, or
+				// the client goes away.
+				ctx := context.Background()
+
+				if hasTimeout {
+					var cancel context.CancelFunc
+
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+					defer cancel()
+				}
+
+				e.Stage = StopFinished
+				e.Err = s.Shutdown(ctx)
+
+				settings.audit(e)
+
+				return e.Err
+			}
+
+			if !settings.wait {
+				writeStopResponse(w, http.StatusAccepted, resp)
+
+				// Shutdown waits for in-flight requests, including this one,
+				// so it can't block the response.
+				go func() {
+					_ = shutdown()
+				}()
+
+				return
+			}
+
+			if err := shutdown(); err != nil {
+				WriteError(w, r, err)
+
+				return
+			}
+
+			resp.Message = "stopped"
+
+			writeStopResponse(w, http.StatusOK, resp)
 		}),
-		Method: http.MethodGet,
+		Method: http.MethodPost,
 		Path:   "/stop",
+
+		// Waiting for shutdown outlasts request timeouts, e.g.: a drain delay.
+		Streaming: settings.wait,
 	}
 }
@@ -0,0 +1,199 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Records shutdown calls.
+type fakeShutdowner struct {
+	calls        chan context.Context
+	err          error
+	shuttingDown bool
+}
+
+// Shutdown interface implementation.
+func (f *fakeShutdowner) Shutdown(ctx context.Context) error {
+	f.calls <- ctx
+
+	return f.err
+}
+
+// ShuttingDown interface implementation.
+func (f *fakeShutdowner) ShuttingDown() bool {
+	return f.shuttingDown
+}
+
+func TestStop(t *testing.T) {
+	type args struct {
+		opts         []StopOption
+		remoteAddr   string
+		token        string
+		timeout      string
+		shutdownErr  error
+		shuttingDown bool
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantStatus int
+		wantStage  StopStage
+		wantWho    string
+		wantErr    bool
+	}{
+		{
+			name:       "Should work - localhost, by default",
+			args:       args{remoteAddr: "127.0.0.1:1234", timeout: "5s"},
+			wantStatus: http.StatusAccepted,
+			wantStage:  StopFinished,
+			wantWho:    "127.0.0.1:1234",
+		},
+		{
+			name:       "Should fail - not localhost",
+			args:       args{remoteAddr: "192.0.2.1:1234"},
+			wantStatus: http.StatusForbidden,
+			wantStage:  StopDenied,
+			wantErr:    true,
+		},
+		{
+			name: "Should work - token",
+			args: args{
+				opts:       []StopOption{WithStopToken("secret")},
+				remoteAddr: "192.0.2.1:1234",
+				token:      "secret",
+			},
+			wantStatus: http.StatusAccepted,
+			wantStage:  StopFinished,
+			wantWho:    "token",
+		},
+		{
+			name: "Should fail - wrong token",
+			args: args{
+				opts:       []StopOption{WithStopToken("secret"), WithStopLocalhost()},
+				remoteAddr: "192.0.2.1:1234",
+				token:      "wrong",
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantStage:  StopDenied,
+			wantErr:    true,
+		},
+		{
+			name:       "Should fail - no client certificate",
+			args:       args{opts: []StopOption{WithStopIdentities("ops")}, remoteAddr: "127.0.0.1:1234"},
+			wantStatus: http.StatusUnauthorized,
+			wantStage:  StopDenied,
+			wantErr:    true,
+		},
+		{
+			name:       "Should fail - invalid timeout",
+			args:       args{remoteAddr: "127.0.0.1:1234", timeout: "soon"},
+			wantStatus: http.StatusBadRequest,
+			wantStage:  StopDenied,
+			wantWho:    "127.0.0.1:1234",
+			wantErr:    true,
+		},
+		{
+			name:       "Should work - wait",
+			args:       args{opts: []StopOption{WithStopWait()}, remoteAddr: "127.0.0.1:1234", timeout: "5s"},
+			wantStatus: http.StatusOK,
+			wantStage:  StopFinished,
+			wantWho:    "127.0.0.1:1234",
+		},
+		{
+			name: "Should fail - wait, shutdown error",
+			args: args{
+				opts:        []StopOption{WithStopWait()},
+				remoteAddr:  "127.0.0.1:1234",
+				shutdownErr: errors.New("tasks failed"),
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantStage:  StopFinished,
+			wantWho:    "127.0.0.1:1234",
+			wantErr:    true,
+		},
+		{
+			name:       "Should fail - already shutting down",
+			args:       args{remoteAddr: "127.0.0.1:1234", shuttingDown: true},
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeShutdowner{
+				calls:        make(chan context.Context, 1),
+				err:          tt.args.shutdownErr,
+				shuttingDown: tt.args.shuttingDown,
+			}
+
+			events := make(chan StopEvent, 2)
+
+			h := Stop(s, append(tt.args.opts, WithStopAudit(func(e StopEvent) {
+				events <- e
+			}))...)
+
+			r := httptest.NewRequest(http.MethodPost, "/stop?timeout="+tt.args.timeout, nil)
+			r.RemoteAddr = tt.args.remoteAddr
+
+			if tt.args.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.args.token)
+			}
+
+			w := httptest.NewRecorder()
+
+			h.Handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected %d got %d, %s", tt.wantStatus, w.Code, w.Body)
+			}
+
+			if tt.wantStage == "" {
+				return
+			}
+
+			var e StopEvent
+
+			for e.Stage != tt.wantStage {
+				select {
+				case e = <-events:
+				case <-time.After(time.Second):
+					t.Fatalf("Expected %s audit event", tt.wantStage)
+				}
+			}
+
+			if e.Who != tt.wantWho {
+				t.Fatalf("Expected %s got %s", tt.wantWho, e.Who)
+			}
+
+			if (e.Err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v got %v", tt.wantErr, e.Err)
+			}
+
+			if tt.wantStage != StopFinished {
+				return
+			}
+
+			// Second request conflicts.
+			w = httptest.NewRecorder()
+
+			h.Handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected %d got %d", http.StatusConflict, w.Code)
+			}
+
+			if ctx := <-s.calls; tt.args.timeout != "" {
+				if _, ok := ctx.Deadline(); !ok {
+					t.Fatal("Expected drain timeout")
+				}
+			}
+		})
+	}
+}
@@ -5,18 +5,144 @@
 package logger
 
 import (
+	"io"
 	"log"
+	"os"
+	"sync"
+	"sync/atomic"
 
+	"github.com/saucelabs/customerror"
 	"github.com/saucelabs/sypl"
+	"github.com/saucelabs/sypl/flag"
 	"github.com/saucelabs/sypl/level"
+	"github.com/saucelabs/sypl/message"
 	"github.com/saucelabs/sypl/output"
 	"github.com/saucelabs/sypl/processor"
-	"github.com/saucelabs/sypl/status"
+	"github.com/saucelabs/sypl/shared"
 )
 
 // Global, singleton, cached logger. It's safe to be retrieved via `Get`.
 var l *sypl.Sypl
 
+// Controller changes the levels, and the file of a logger set up via `Setup`,
+// at runtime. It's safe for concurrent use.
+type Controller struct {
+	consoleMuted int32
+	file         *fileWriter
+	level        int32
+	requestLevel int32
+}
+
+// Writes to the current log file, if any.
+type fileWriter struct {
+	f *os.File
+	m sync.Mutex
+}
+
+// Write interface implementation. Without a file, it's discarded.
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	fw.m.Lock()
+	defer fw.m.Unlock()
+
+	if fw.f == nil {
+		return len(p), nil
+	}
+
+	return fw.f.Write(p)
+}
+
+// Opens the file at `path`, "-" means `stdout`, and "" none.
+func openLogFile(path string) (*os.File, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		return os.Stdout, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, shared.DefaultFileMode)
+	if err != nil {
+		return nil, customerror.NewFailedToError("open log file "+path, customerror.WithError(err))
+	}
+
+	return f, nil
+}
+
+// Mutes messages above the current level, or all of them if `muted`.
+func gate(current, muted *int32) processor.IProcessor {
+	return processor.New("Gate", func(m message.IMessage) error {
+		if m.GetFlag() == flag.Force || m.GetFlag() == flag.SkipAndForce {
+			return nil
+		}
+
+		if (muted != nil && atomic.LoadInt32(muted) == 1) || m.GetLevel() > level.Level(atomic.LoadInt32(current)) {
+			m.SetFlag(flag.Mute)
+		}
+
+		return nil
+	})
+}
+
+// Reconfigure changes levels, and the file path. If the file fails to open,
+// nothing changes.
+func (c *Controller) Reconfigure(logLevel, requestLogLevel, logFilePath string) error {
+	logLevelAsLevel, err := level.FromString(logLevel)
+	if err != nil {
+		return customerror.NewInvalidError("log level", customerror.WithError(err))
+	}
+
+	requestLogLevelAsLevel, err := level.FromString(requestLogLevel)
+	if err != nil {
+		return customerror.NewInvalidError("request log level", customerror.WithError(err))
+	}
+
+	f, err := openLogFile(logFilePath)
+	if err != nil {
+		return err
+	}
+
+	c.file.m.Lock()
+	previous := c.file.f
+	c.file.f = f
+	c.file.m.Unlock()
+
+	if previous != nil && previous != os.Stdout && previous != f {
+		previous.Close()
+	}
+
+	// "-" special case makes the `File` Output behave as `Console`, also
+	// writing to `stdout` causing duplicated messages.
+	consoleMuted := int32(0)
+
+	if logFilePath == "-" {
+		consoleMuted = 1
+	}
+
+	atomic.StoreInt32(&c.consoleMuted, consoleMuted)
+	atomic.StoreInt32(&c.level, int32(logLevelAsLevel))
+	atomic.StoreInt32(&c.requestLevel, int32(requestLogLevelAsLevel))
+
+	return nil
+}
+
+// RequestWriter returns a writer which logs requests through `l`, at the
+// current request level.
+func (c *Controller) RequestWriter(l sypl.ISypl) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		l.PrintMessage(message.New(level.Level(atomic.LoadInt32(&c.requestLevel)), string(p)))
+
+		return len(p), nil
+	})
+}
+
+// Adapts a function to `io.Writer`.
+type writerFunc func(p []byte) (int, error)
+
+// Write interface implementation.
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
 // Get safely returns the global application logger.
 func Get() *sypl.Sypl {
 	if l != nil {
@@ -28,33 +154,36 @@ func Get() *sypl.Sypl {
 	return nil
 }
 
-// Setup logger.
-func Setup(name, logLevel, requestLogLevel, logFilePath string) *sypl.Sypl {
-	logLevelAsLevel := level.MustFromString(logLevel)
+// Setup logger. Levels, and the file can be changed at runtime via the
+// returned controller.
+func Setup(name, logLevel, requestLogLevel, logFilePath string) (*sypl.Sypl, *Controller) {
 	requesLogLevelAsLevel := level.MustFromString(requestLogLevel)
 
+	c := &Controller{file: &fileWriter{}}
+
+	// Levels are enforced by the `Gate` processor, so they can change.
 	l = sypl.NewDefault(
 		name,
-		logLevelAsLevel,
+		level.Trace,
 		processor.ChangeFirstCharCase(processor.Lowercase),
 	)
 
+	l.GetOutput("Console").AddProcessors(gate(&c.level, &c.consoleMuted))
+
 	l.SetDefaultIoWriterLevel(requesLogLevelAsLevel)
 
-	// Should only enable File output if path is set.
-	if logFilePath != "" {
-		l.AddOutputs(output.File(
-			logFilePath,
-			logLevelAsLevel,
-			processor.ChangeFirstCharCase(processor.Lowercase),
-		))
-
-		// "-" special case makes the `File` Output behave as `Console`, also
-		// writing to `stdout` causing duplicated messages.
-		if logFilePath == "-" {
-			l.GetOutput("Console").SetStatus(status.Disabled)
-		}
+	// Always registered, it only writes if path is set.
+	l.AddOutputs(output.FileBased(
+		"File",
+		level.Trace,
+		c.file,
+		processor.ChangeFirstCharCase(processor.Lowercase),
+		gate(&c.level, nil),
+	))
+
+	if err := c.Reconfigure(logLevel, requestLogLevel, logFilePath); err != nil {
+		log.Fatalf("%s File Output: %s", shared.ErrorPrefix, err)
 	}
 
-	return l
+	return l, c
 }
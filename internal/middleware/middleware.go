@@ -1,16 +1,72 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	"github.com/saucelabs/sypl"
+	"github.com/saucelabs/webserver/handler"
 )
 
-// Log requests in the Apache Combined Log Format.
-func Logger(l sypl.ISypl) mux.MiddlewareFunc {
+// Log requests in the Apache Combined Log Format. The client certificate
+// identity, if any, is logged as the remote user.
+func Logger(l io.Writer) mux.MiddlewareFunc {
 	return func(h http.Handler) http.Handler {
-		return handlers.CombinedLoggingHandler(l, h)
+		loggingHandler := handlers.CombinedLoggingHandler(l, h)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if identity := handler.PeerIdentity(r); identity != "" && r.URL.User == nil {
+				u := *r.URL
+				u.User = url.User(identity)
+
+				r = r.Clone(r.Context())
+				r.URL = &u
+			}
+
+			loggingHandler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Max length of a client provided request ID.
+const maxRequestIDLength = 128
+
+// Verifies if the client provided request ID is safe to be logged, and echoed.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
 	}
+
+	return true
+}
+
+// RequestID ensures every request has an ID, see `handler.RequestID`. A valid
+// client provided one is kept, otherwise a random one is generated. It's echoed
+// in the response.
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isValidRequestID(r.Header.Get(handler.RequestIDHeader)) {
+			b := make([]byte, 16)
+
+			_, _ = rand.Read(b)
+
+			r.Header.Set(handler.RequestIDHeader, hex.EncodeToString(b))
+		}
+
+		w.Header().Set(handler.RequestIDHeader, r.Header.Get(handler.RequestIDHeader))
+
+		h.ServeHTTP(w, r)
+	})
 }
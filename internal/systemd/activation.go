@@ -0,0 +1,96 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Consts, and vars.
+//////
+
+// First file descriptor passed by systemd, see `sd_listen_fds(3)`.
+const listenFDsStart = 3
+
+// ErrNoActivation indicates the process wasn't socket activated.
+var ErrNoActivation = customerror.NewMissingError(
+	"socket activation, LISTEN_PID, or LISTEN_FDS not set for this process",
+)
+
+var (
+	// Passed files, by file descriptor. Kept open, so listeners can be created
+	// more than once, e.g.: by multiple servers.
+	files      = map[int]*os.File{}
+	filesMutex sync.Mutex
+)
+
+//////
+// Helpers.
+//////
+
+// Returns the file descriptor passed by systemd named `name`. An empty `name`
+// picks the first one.
+func fd(name string) (int, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return 0, ErrNoActivation
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return 0, ErrNoActivation
+	}
+
+	if name == "" {
+		return listenFDsStart, nil
+	}
+
+	for i, fdName := range strings.Split(os.Getenv("LISTEN_FDNAMES"), ":") {
+		if fdName == name && i < n {
+			return listenFDsStart + i, nil
+		}
+	}
+
+	return 0, customerror.NewMissingError("socket activation file descriptor named " + name)
+}
+
+//////
+// Exported functionalities.
+//////
+
+// Listener returns the listener passed by systemd socket activation, see
+// `LISTEN_FDS`, `LISTEN_PID`, and `LISTEN_FDNAMES`. An empty `name` picks the
+// first one.
+func Listener(name string) (net.Listener, error) {
+	fd, err := fd(name)
+	if err != nil {
+		return nil, err
+	}
+
+	filesMutex.Lock()
+	defer filesMutex.Unlock()
+
+	f, ok := files[fd]
+	if !ok {
+		f = os.NewFile(uintptr(fd), "systemd:"+name)
+
+		files[fd] = f
+	}
+
+	// Duplicates the file descriptor.
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, customerror.NewFailedToError("use socket activation file descriptor", customerror.WithError(err))
+	}
+
+	return l, nil
+}
@@ -0,0 +1,3 @@
+// Package systemd provides systemd integration: socket activation, and service
+// notifications, without depending on libsystemd.
+package systemd
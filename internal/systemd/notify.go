@@ -0,0 +1,80 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Consts, and vars.
+//////
+
+// Service notifications, see `sd_notify(3)`.
+const (
+	// NotifyReady tells the service finished starting up.
+	NotifyReady = "READY=1"
+
+	// NotifyStopping tells the service is shutting down.
+	NotifyStopping = "STOPPING=1"
+
+	// NotifyWatchdog keeps the service watchdog alive.
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+//////
+// Exported functionalities.
+//////
+
+// Notify sends `state` to systemd, via `NOTIFY_SOCKET`. It returns false if
+// notifications aren't supported, e.g.: `NOTIFY_SOCKET` isn't set.
+func Notify(state string) (bool, error) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return false, nil
+	}
+
+	// Abstract namespace socket.
+	if socketAddr[0] == '@' {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketAddr, Net: "unixgram"})
+	if err != nil {
+		return false, customerror.NewFailedToError("connect to systemd notify socket", customerror.WithError(err))
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, customerror.NewFailedToError("notify systemd", customerror.WithError(err))
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval returns how often the watchdog should be notified. It
+// returns false if the watchdog isn't enabled for this process, see
+// `WATCHDOG_USEC`, and `WATCHDOG_PID`.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+
+	if watchdogPID := os.Getenv("WATCHDOG_PID"); watchdogPID != "" {
+		if pid, err := strconv.Atoi(watchdogPID); err != nil || pid != os.Getpid() {
+			return 0, false
+		}
+	}
+
+	// Notifies twice per timeout, as recommended by `sd_watchdog_enabled(3)`.
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
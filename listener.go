@@ -0,0 +1,86 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/internal/systemd"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const (
+	defaultSocketMode    os.FileMode = 0o660
+	systemdAddressPrefix             = "systemd:"
+	unixAddressPrefix                = "unix:"
+)
+
+//////
+// Helpers.
+//////
+
+// Listens on `address`, which is either a TCP address (`host:port`), a Unix
+// domain socket (`unix:/path.sock`), or a systemd socket activation file
+// descriptor (`systemd:`, or `systemd:name`).
+func listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, systemdAddressPrefix):
+		return systemd.Listener(strings.TrimPrefix(address, systemdAddressPrefix))
+	case strings.HasPrefix(address, unixAddressPrefix):
+		return listenUnix(strings.TrimPrefix(address, unixAddressPrefix), socketMode)
+	default:
+		return net.Listen("tcp", address)
+	}
+}
+
+// Listens on the Unix domain socket `path`, with `mode` permissions. Stale
+// sockets, e.g.: left behind by a crash, are removed. The socket is removed
+// once the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, customerror.NewInvalidError(path + ", it exists, and isn't a socket")
+		}
+
+		// Don't steal the socket from a live server.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+
+			return nil, customerror.NewFailedToError("listen on " + path + ", address already in use")
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, customerror.NewFailedToError("remove stale socket "+path, customerror.WithError(err))
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+
+		return nil, customerror.NewFailedToError("set socket permissions", customerror.WithError(err))
+	}
+
+	return listener, nil
+}
+
+// Formats `addr` the same way `Address` is set.
+func formatAddr(addr net.Addr) string {
+	if addr.Network() == "unix" {
+		return unixAddressPrefix + addr.String()
+	}
+
+	return addr.String()
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+// Returns a client which always dials the Unix domain socket `path`.
+func unixClient(path string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+func TestNew_unixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+
+	// Simulates a stale socket, e.g.: left behind by a crash.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	testServer, err := New(serverName, "unix:"+path,
+		WithHandlers(handler.Liveness()),
+		WithSocketMode(0o600),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	if addr := waitReady(t, testServer); addr != "unix:"+path {
+		t.Fatalf("Expected %s got %s", "unix:"+path, addr)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected %v got %v", os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	resp, err := unixClient(path).Get("http://unix/liveness")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %v got %v", http.StatusOK, resp.StatusCode)
+	}
+
+	if err := testServer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Expected socket to be removed once the server stops")
+	}
+}
+
+func TestNew_unixSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer live.Close()
+
+	testServer, err := New(serverName, "unix:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testServer.Start(); err == nil {
+		t.Fatal("Expected live socket not to be taken over")
+	}
+}
+
+// Not a real test. It's the systemd socket activated process, see
+// `TestNew_systemdSocketActivation`.
+func TestHelperSystemdProcess(t *testing.T) {
+	if os.Getenv("WEBSERVER_TEST_SYSTEMD") != "1" {
+		t.Skip("helper process")
+	}
+
+	// systemd sets it to the activated process PID.
+	os.Setenv("LISTEN_PID", fmt.Sprintf("%d", os.Getpid()))
+
+	testServer, err := New(serverName, "systemd:web", WithHandlers(handler.Liveness()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_systemdSocketActivation(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	f, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	// Passes the listener as file descriptor `3`, named `web`.
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperSystemdProcess$")
+	cmd.Env = append(os.Environ(), "WEBSERVER_TEST_SYSTEMD=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=admin:web")
+	cmd.ExtraFiles = []*os.File{nil, f}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	var body string
+
+	for i := 0; i < 50; i++ {
+		resp, err := c.Get(fmt.Sprintf("http://%s/liveness", listener.Addr()))
+		if err != nil {
+			time.Sleep(100 * time.Millisecond)
+
+			continue
+		}
+
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		body = string(b)
+
+		break
+	}
+
+	if !strings.Contains(body, http.StatusText(http.StatusOK)) {
+		t.Fatalf("Expected %s got %s", http.StatusText(http.StatusOK), body)
+	}
+}
@@ -1,7 +1,7 @@
 package metric
 
 import (
-	"github.com/saucelabs/webserver/internal/validation"
+	"github.com/saucelabs/webserver/validation"
 )
 
 //////
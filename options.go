@@ -18,6 +18,10 @@
 package webserver
 
 import (
+	"context"
+	"log"
+	"net"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -44,6 +48,210 @@ func WithRouter(router *mux.Router) Option {
 	}
 }
 
+// WithSignals sets the OS signals which gracefully shut down the server, e.g.:
+// `os.Interrupt`, and `syscall.SIGTERM`. By default, no signal is handled,
+// leaving signal handling up to the application, see `Run`.
+func WithSignals(signals ...os.Signal) Option {
+	return func(s *Server) {
+		s.signals = signals
+	}
+}
+
+// WithParallelShutdownTasks makes shutdown tasks run in parallel, instead of
+// in the order they were registered.
+func WithParallelShutdownTasks() Option {
+	return func(s *Server) {
+		s.ParallelShutdownTasks = true
+	}
+}
+
+// WithShutdownTasks registers tasks which run once the server is shut down.
+// See `OnShutdown`.
+func WithShutdownTasks(tasks ...ShutdownTask) Option {
+	return func(s *Server) {
+		s.shutdownTasks = tasks
+	}
+}
+
+// WithShutdownDrainDelay sets for how long the server keeps serving requests,
+// while reporting not-ready, once shutdown starts. See `ShutdownDrainDelay`.
+func WithShutdownDrainDelay(delay time.Duration) Option {
+	return func(s *Server) {
+		s.Timeout.ShutdownDrainDelay = delay
+	}
+}
+
+// WithTLS enables HTTPS. Certificates are reloaded from disk once they change.
+// See `TLS`.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		if s.TLS == nil {
+			s.TLS = &TLS{ReloadInterval: defaultTLSReloadInterval}
+		}
+
+		s.TLS.CertFile = certFile
+		s.TLS.KeyFile = keyFile
+	}
+}
+
+// WithClientCA enables mutual TLS, clients are required to present a valid
+// certificate signed by any of the CAs. It requires `WithTLS`.
+//
+// NOTE: Use `handler.PeerIdentity` to retrieve the client identity.
+func WithClientCA(caFiles ...string) Option {
+	return func(s *Server) {
+		if s.TLS == nil {
+			s.TLS = &TLS{ReloadInterval: defaultTLSReloadInterval}
+		}
+
+		s.TLS.ClientCAFiles = caFiles
+	}
+}
+
+// WithReloadSignals sets the OS signals which reload the server, e.g.:
+// `syscall.SIGHUP`. See `Reload`.
+func WithReloadSignals(signals ...os.Signal) Option {
+	return func(s *Server) {
+		s.reloadSignals = signals
+	}
+}
+
+// WithConfigReloadInterval sets how often the configuration file is checked
+// for changes, reloading it, default: 0, disabled. Only applies to servers
+// created via `NewFromConfig`.
+func WithConfigReloadInterval(interval time.Duration) Option {
+	return func(s *Server) {
+		s.configReloadInterval = interval
+	}
+}
+
+// WithUpgradeSignals sets the OS signals which upgrade the server, e.g.:
+// `syscall.SIGUSR2`. See `Upgrade`. While upgrading, the server keeps handling
+// other signals, and context cancelation. Upgrade signals received meanwhile
+// are ignored.
+func WithUpgradeSignals(signals ...os.Signal) Option {
+	return func(s *Server) {
+		s.upgradeSignals = signals
+	}
+}
+
+// WithSocketMode sets the Unix domain socket permissions, e.g.: `0600`.
+func WithSocketMode(mode os.FileMode) Option {
+	return func(s *Server) {
+		s.SocketMode = mode
+	}
+}
+
+// WithSystemdNotify notifies systemd about the server lifecycle, see
+// `EnableSystemdNotify`. It's a no-op if `NOTIFY_SOCKET` isn't set.
+func WithSystemdNotify() Option {
+	return func(s *Server) {
+		s.EnableSystemdNotify = true
+	}
+}
+
+// WithSystemdWatchdog sets liveness determiners gating the systemd watchdog,
+// see `WithSystemdNotify`. It's only notified while ALL of them, and the
+// server, are ready, e.g.: a stalled worker reported by
+// `handler.NewHeartbeatDeterminer`, so systemd restarts the process. By
+// default, only the server state is consulted.
+//
+// NOTE: Don't reuse readiness determiners depending on external services,
+// e.g.: an unreachable database shouldn't make systemd restart a healthy
+// process.
+func WithSystemdWatchdog(watchdogDeterminers ...*handler.ReadinessDeterminer) Option {
+	return func(s *Server) {
+		s.watchdogDeterminers = watchdogDeterminers
+	}
+}
+
+// WithRequestTimeoutMode sets how requests time out, either
+// `TimeoutModeBuffer`, or `TimeoutModeContext`. See `RequestTimeoutMode`.
+func WithRequestTimeoutMode(mode string) Option {
+	return func(s *Server) {
+		s.Timeout.RequestTimeoutMode = mode
+	}
+}
+
+// WithReadHeaderTimeout sets the maximum duration for reading the request
+// headers. See `ReadHeaderTimeout`.
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.Timeout.ReadHeaderTimeout = timeout
+	}
+}
+
+// WithIdleTimeout sets the maximum duration to wait for the next request on
+// keep-alive connections. See `IdleTimeout`.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.Timeout.IdleTimeout = timeout
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of the request headers.
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *Server) {
+		s.MaxHeaderBytes = n
+	}
+}
+
+// WithErrorLog sets the logger for errors accepting connections, and
+// unexpected handler behavior. By default, they're logged by the server
+// logger, at the error level.
+func WithErrorLog(l *log.Logger) Option {
+	return func(s *Server) {
+		s.errorLog = l
+	}
+}
+
+// WithBaseContext sets the base context for incoming requests.
+//
+// SEE: `http.Server.BaseContext`.
+func WithBaseContext(fn func(net.Listener) context.Context) Option {
+	return func(s *Server) {
+		s.baseContext = fn
+	}
+}
+
+// WithConnContext sets a function which modifies the context for new
+// connections.
+//
+// SEE: `http.Server.ConnContext`.
+func WithConnContext(fn func(ctx context.Context, c net.Conn) context.Context) Option {
+	return func(s *Server) {
+		s.connContext = fn
+	}
+}
+
+// WithAdminAddress moves built-in operational handlers (e.g.: liveness,
+// readiness, metrics, and stop) to a dedicated listener on `address`, leaving
+// the main router only with business traffic. See `Admin`, and
+// `GetAdminRouter`.
+func WithAdminAddress(address string) Option {
+	return func(s *Server) {
+		if s.Admin == nil {
+			s.Admin = newAdmin()
+		}
+
+		s.Admin.Address = address
+	}
+}
+
+// WithAdminTimeout sets the maximum duration for each individual admin
+// listener timeouts. It requires `WithAdminAddress`.
+func WithAdminTimeout(read, request, write time.Duration) Option {
+	return func(s *Server) {
+		if s.Admin == nil {
+			s.Admin = newAdmin()
+		}
+
+		s.Admin.ReadTimeout = read
+		s.Admin.RequestTimeout = request
+		s.Admin.WriteTimeout = write
+	}
+}
+
 // WithTimeout sets the maximum duration for each individual timeouts.
 func WithTimeout(read, request, inflight, tasks, write time.Duration) Option {
 	return func(s *Server) {
@@ -110,6 +318,9 @@ func WithLogging(console, request, filepath string) Option {
 // passed. In this case, only if ALL are ready, the server will be considered
 // ready.
 //
+// It adds the readiness probes: `/readiness`, and the Kubernetes-style
+// `/readyz`, and `/readyz/{name}`.
+//
 // NOTE: Use `handler.NewReadinessDeterminer` to bring your own determiner.
 func WithReadiness(readinessDeterminers ...*handler.ReadinessDeterminer) Option {
 	return func(s *Server) {
@@ -117,6 +328,37 @@ func WithReadiness(readinessDeterminers ...*handler.ReadinessDeterminer) Option
 	}
 }
 
+// WithStartup sets startup determiners, adding the startup probe
+// (`/startupz`). It fails until ALL of them, and the server, were ready at
+// least once, e.g.: warming caches, or running migrations. See
+// `handler.Startupz`.
+func WithStartup(startupDeterminers ...*handler.ReadinessDeterminer) Option {
+	return func(s *Server) {
+		s.startupDeterminers = startupDeterminers
+	}
+}
+
+// WithStopOptions sets the built-in stop handler options, e.g.:
+// `handler.WithStopToken`. By default, it only authorizes requests from
+// localhost. Without an admin listener, see `WithAdminAddress`, it's only
+// served if requests are authenticated, see `handler.StopAuthenticated`. See
+// `NewDefault`, and `handler.Stop`.
+func WithStopOptions(opts ...handler.StopOption) Option {
+	return func(s *Server) {
+		s.stopOptions = opts
+	}
+}
+
+// WithChecks sets readiness checks, which run in the background while the
+// server runs, see `handler.NewCheckDeterminer`. They're readiness
+// determiners too, see `WithReadiness`. If metrics are enabled, checks runs,
+// failures, and latency are published as `{name}_checks`.
+func WithChecks(checks ...*handler.CheckDeterminer) Option {
+	return func(s *Server) {
+		s.checks = checks
+	}
+}
+
 // WithHandlers sets the list of pre-loaded handlers.
 //
 // NOTE: Use `handler.New` to bring your own handler.
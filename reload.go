@@ -0,0 +1,289 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/validation"
+)
+
+//////
+// Consts, and vars.
+//////
+
+// Configuration settings which are applied at runtime, see `Reload`. Others
+// require a restart.
+var hotSwappableSettings = map[string]bool{
+	"logging.console_level":        true,
+	"logging.filepath":             true,
+	"logging.request_level":        true,
+	"readiness_overrides":          true,
+	"timeout.request_timeout":      true,
+	"timeout.request_timeout_mode": true,
+}
+
+//////
+// Definitions.
+//////
+
+// Reloads the configuration file.
+type configReloader struct {
+	path string
+
+	// Configuration in effect. Settings which require a restart keep the
+	// values the server started with.
+	config  *Config
+	modTime time.Time
+	m       sync.Mutex
+}
+
+//////
+// Helpers.
+//////
+
+// Flattens `v`, a struct, into `settings`, keyed by the JSON path, e.g.:
+// `timeout.request_timeout`.
+func flattenSettings(prefix string, v reflect.Value, settings map[string]string) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			settings[prefix] = "none"
+
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		settings[prefix] = fmt.Sprint(v.Interface())
+
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		flattenSettings(name, v.Field(i), settings)
+	}
+}
+
+// Returns the differences between `current`, and `next`, formatted as
+// `setting: old -> new`. They're split into the ones applied at runtime, and
+// the ones which require a restart.
+func diffConfig(current, next *Config) (applied, requireRestart []string) {
+	currentSettings := map[string]string{}
+	nextSettings := map[string]string{}
+
+	flattenSettings("", reflect.ValueOf(current), currentSettings)
+	flattenSettings("", reflect.ValueOf(next), nextSettings)
+
+	names := []string{}
+
+	for name := range currentSettings {
+		names = append(names, name)
+	}
+
+	for name := range nextSettings {
+		if _, ok := currentSettings[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if currentSettings[name] == nextSettings[name] {
+			continue
+		}
+
+		change := fmt.Sprintf("%s: %s -> %s", name, currentSettings[name], nextSettings[name])
+
+		if hotSwappableSettings[name] {
+			applied = append(applied, change)
+		} else {
+			requireRestart = append(requireRestart, change)
+		}
+	}
+
+	return applied, requireRestart
+}
+
+//////
+// Server.
+//////
+
+// Applies readiness overrides, by determiner name. Overrides applied by the
+// previous configuration, but not listed, are cleared. Others, e.g.: set via
+// `SetOverride`, aren't touched. The server state determiner can't be
+// overridden, e.g.: a draining server isn't ready. If any name is unknown,
+// nothing changes.
+func (s *Server) applyReadinessOverrides(overrides map[string]bool) error {
+	determiners := map[string]*handler.ReadinessDeterminer{}
+
+	for _, d := range s.readinessDeterminers {
+		determiners[d.GetName()] = d
+	}
+
+	for name := range overrides {
+		if _, ok := determiners[name]; !ok {
+			return customerror.NewInvalidError("readiness override, unknown determiner " + name)
+		}
+	}
+
+	for name := range s.readinessOverrides {
+		if _, ok := overrides[name]; !ok {
+			if d, ok := determiners[name]; ok {
+				d.ClearOverride()
+			}
+		}
+	}
+
+	for name, v := range overrides {
+		determiners[name].SetOverride(v)
+	}
+
+	s.readinessOverrides = overrides
+
+	return nil
+}
+
+// Reloads the configuration file, applying settings which can change at
+// runtime: log levels, log file, request timeouts, and readiness overrides.
+// Changes are logged. If the configuration is invalid, or can't be applied,
+// nothing changes.
+func (s *Server) reloadConfig() error {
+	r := s.configReloader
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	next, err := LoadConfigFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	applied, requireRestart := diffConfig(r.config, next)
+
+	// Settings which require a restart keep their current values.
+	config := *r.config
+	logging := *next.Logging
+	timeout := *r.config.Timeout
+
+	timeout.RequestTimeout = next.Timeout.RequestTimeout
+	timeout.RequestTimeoutMode = next.Timeout.RequestTimeoutMode
+
+	config.Logging = &logging
+	config.Timeout = &timeout
+	config.ReadinessOverrides = next.ReadinessOverrides
+
+	// e.g.: request timeout needs to be smaller than the current read timeout.
+	if err := validation.ValidateStruct(&config); err != nil {
+		return err
+	}
+
+	if err := s.applyReadinessOverrides(config.ReadinessOverrides); err != nil {
+		return err
+	}
+
+	if err := s.loggerController.Reconfigure(logging.ConsoleLevel, logging.RequestLevel, logging.Filepath); err != nil {
+		// Readiness overrides were already applied, restore them.
+		_ = s.applyReadinessOverrides(r.config.ReadinessOverrides)
+
+		return err
+	}
+
+	s.requestTimeout.Store(requestTimeoutSettings{
+		mode:    timeout.RequestTimeoutMode,
+		timeout: timeout.RequestTimeout,
+	})
+
+	r.config = &config
+
+	if len(applied) > 0 {
+		s.GetLogger().Infolnf("Configuration reloaded. %s", strings.Join(applied, ", "))
+	} else {
+		s.GetLogger().Debuglnf("Configuration reloaded, nothing to apply")
+	}
+
+	if len(requireRestart) > 0 {
+		s.GetLogger().Warnlnf("Configuration changes require a restart, not applied. %s", strings.Join(requireRestart, ", "))
+	}
+
+	return nil
+}
+
+// Verifies if the configuration file changed since last reload.
+func (s *Server) configChanged() bool {
+	r := s.configReloader
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+
+	return !info.ModTime().Equal(r.modTime)
+}
+
+// Polls the configuration file for changes every `interval` until `ctx` is
+// done, reloading it.
+func (s *Server) watchConfig(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.configChanged() {
+				continue
+			}
+
+			if err := s.reloadConfig(); err != nil {
+				s.GetLogger().Errorlnf("Configuration changed, but failed to reload, keeping current one. %s", err)
+			}
+		}
+	}
+}
+
+//////
+// Factory.
+//////
+
+// Creates a configuration reloader for the file at `path`, loaded as `c`.
+func newConfigReloader(path string, c *Config) *configReloader {
+	r := &configReloader{path: path, config: c}
+
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	return r
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+//////
+// Definitions.
+//////
+
+// Signals handled while running, see `Run`.
+type runSignals struct {
+	// Gracefully shut down the server, see `WithSignals`.
+	shutdown chan os.Signal
+
+	// Reload the server, see `WithReloadSignals`.
+	reload chan os.Signal
+
+	// Upgrade the server, see `WithUpgradeSignals`.
+	upgrade chan os.Signal
+}
+
+//////
+// Server.
+//////
+
+// Acquires the listener, and the admin one, if `Admin` is set. `inherited`
+// reports whether the listener was inherited from an upgrading parent process.
+func (s *Server) listen() (listener, adminListener net.Listener, inherited bool, err error) {
+	listener, inherited, err = acquireListener(envUpgradeAddress, upgradeListenerFD, s.Address, s.SocketMode)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if s.Admin != nil {
+		adminListener, _, err = acquireListener(envUpgradeAdminAddress, upgradeAdminListenerFD, s.Admin.Address, s.SocketMode)
+		if err != nil {
+			listener.Close()
+
+			return nil, nil, false, err
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.addr = listener.Addr()
+	s.listener = listener
+
+	if adminListener != nil {
+		s.adminAddr = adminListener.Addr()
+		s.adminListener = adminListener
+	}
+
+	return listener, adminListener, inherited, nil
+}
+
+// Watches TLS certificates, and the configuration file for changes, until
+// `stop` is called.
+func (s *Server) watch() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if s.certReloader != nil && s.TLS.ReloadInterval > 0 {
+		go s.certReloader.watch(ctx, s.TLS.ReloadInterval, s.GetLogger())
+	}
+
+	if s.configReloader != nil && s.configReloadInterval > 0 {
+		go s.watchConfig(ctx, s.configReloadInterval)
+	}
+
+	return cancel
+}
+
+// Listens for "catchable" OS signals, forget SIGKILL... Only signals
+// explicitly set via `WithSignals`, `WithReloadSignals`, and
+// `WithUpgradeSignals` are handled, until `stop` is called.
+func (s *Server) notifySignals() (signals runSignals, stop func()) {
+	signals = runSignals{
+		shutdown: make(chan os.Signal, 1),
+		reload:   make(chan os.Signal, 1),
+		upgrade:  make(chan os.Signal, 1),
+	}
+
+	notify := func(c chan os.Signal, sigs []os.Signal) {
+		if len(sigs) > 0 {
+			signal.Notify(c, sigs...)
+		}
+	}
+
+	notify(signals.shutdown, s.signals)
+	notify(signals.reload, s.reloadSignals)
+	notify(signals.upgrade, s.upgradeSignals)
+
+	return signals, func() {
+		signal.Stop(signals.shutdown)
+		signal.Stop(signals.reload)
+		signal.Stop(signals.upgrade)
+	}
+}
+
+// Blocks until the server fails, is shut down, `ctx` is canceled, or a signal
+// shuts it down, reloading, and upgrading meanwhile. It returns whether the
+// server needs to be gracefully shut down, otherwise the result of `Run`.
+func (s *Server) wait(
+	ctx context.Context,
+	signals runSignals,
+	serverErr, adminErr <-chan error,
+	admin bool,
+) (bool, error) {
+	// Result of an upgrade in progress, if any.
+	upgradeErr := make(chan error, 1)
+	upgrading := false
+
+	for {
+		select {
+		case err := <-serverErr:
+			return false, s.served(err, admin)
+		case err := <-adminErr:
+			// `Shutdown` was called, reported by `serverErr`.
+			if errors.Is(err, http.ErrServerClosed) {
+				continue
+			}
+
+			s.GetLogger().Errorlnf("Admin server failed, gracefully shutting down. %s", err)
+
+			_ = s.Shutdown(context.Background())
+
+			return false, err
+		case <-ctx.Done():
+			s.GetLogger().Tracelnf("Context is done (%s), gracefully shutting down", ctx.Err())
+
+			return true, nil
+		case sig := <-signals.shutdown:
+			s.logger.PrintNewLine()
+			s.GetLogger().Tracelnf("Got %s signal, gracefully shutting down", sig)
+
+			// Let Go terminate the program if we get that signal again.
+			signal.Reset(sig)
+
+			return true, nil
+		case sig := <-signals.reload:
+			s.GetLogger().Tracelnf("Got %s signal, reloading", sig)
+
+			if err := s.Reload(); err != nil {
+				s.GetLogger().Errorlnf("Failed to reload, keeping current state. %s", err)
+			}
+		case sig := <-signals.upgrade:
+			if upgrading {
+				s.GetLogger().Tracelnf("Got %s signal, already upgrading", sig)
+
+				continue
+			}
+
+			s.GetLogger().Tracelnf("Got %s signal, upgrading", sig)
+
+			upgrading = true
+
+			// Waits for the new process, meanwhile signals, context
+			// cancelation, and errors are still handled.
+			go func() {
+				upgradeErr <- s.Upgrade()
+			}()
+		case err := <-upgradeErr:
+			upgrading = false
+
+			// Once upgraded, the server is shut down, reported by `serverErr`.
+			if err != nil {
+				s.GetLogger().Errorlnf("Failed to upgrade, keep serving. %s", err)
+			}
+		}
+	}
+}
+
+// Returns the result of `Run` once the server stopped serving with `err`.
+func (s *Server) served(err error, admin bool) error {
+	// `Shutdown` was called. Wait for it to finish, and report its result.
+	if errors.Is(err, http.ErrServerClosed) {
+		<-s.shutdownDone
+
+		if admin {
+			<-s.adminDone
+		}
+
+		if s.shutdownErr != nil {
+			return s.shutdownErr
+		}
+
+		// Handed off to the new process, it's a clean stop.
+		s.m.Lock()
+		upgraded := s.upgraded
+		s.m.Unlock()
+
+		if upgraded {
+			err = nil
+		}
+	}
+
+	// Other errors don't require graceful shutdown, but the admin server needs
+	// to stop too.
+	if admin {
+		_ = s.adminServer.Close()
+	}
+
+	s.setState(StateStopped)
+
+	return err
+}
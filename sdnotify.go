@@ -0,0 +1,90 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/internal/systemd"
+)
+
+// Notifies systemd, logging failures.
+func (s *Server) notifySystemd(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		s.GetLogger().Errorlnf("Failed to notify systemd %s. %s", state, err)
+	}
+}
+
+// Integrates the server lifecycle with systemd (`Type=notify` services): sends
+// `READY=1` once serving, `STOPPING=1` once draining, and keeps the watchdog
+// alive meanwhile.
+func (s *Server) systemdStateChange(from, to State) {
+	switch to {
+	case StateServing:
+		s.notifySystemd(systemd.NotifyReady)
+
+		if interval, ok := systemd.WatchdogInterval(); ok {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			s.m.Lock()
+			s.watchdogCancel = cancel
+			s.m.Unlock()
+
+			go s.watchdog(ctx, interval)
+		}
+	case StateDraining, StateStopped:
+		s.m.Lock()
+		if s.watchdogCancel != nil {
+			s.watchdogCancel()
+		}
+		s.m.Unlock()
+
+		if to == StateDraining {
+			s.notifySystemd(systemd.NotifyStopping)
+		}
+	case StateNew, StateStarting:
+	}
+}
+
+// Notifies the systemd watchdog every `interval`, until `ctx` is done, but only
+// while the server, and the watchdog determiners are ready, see
+// `WithSystemdWatchdog`. It's a liveness signal: readiness determiners aren't
+// consulted, e.g.: an unreachable database shouldn't make systemd restart a
+// healthy process.
+func (s *Server) watchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watchdogStates := append([]*handler.ReadinessDeterminer{s.stateDeterminer}, s.watchdogDeterminers...)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if name, alive := allReady(watchdogStates); !alive {
+				s.GetLogger().Debuglnf("%s isn't ready, skipping systemd watchdog notification", name)
+
+				continue
+			}
+
+			s.notifySystemd(systemd.NotifyWatchdog)
+		}
+	}
+}
+
+// Returns whether all `determiners` are ready, otherwise the name of the first
+// one which isn't.
+func allReady(determiners []*handler.ReadinessDeterminer) (string, bool) {
+	for _, d := range determiners {
+		if !d.GetReadiness() {
+			return d.GetName(), false
+		}
+	}
+
+	return "", true
+}
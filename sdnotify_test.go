@@ -0,0 +1,128 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+func TestNew_systemdNotify(t *testing.T) {
+	// Stands in for systemd.
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	notifications := make(chan string, 100)
+
+	go func() {
+		buf := make([]byte, 1024)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+
+			notifications <- string(buf[:n])
+		}
+	}()
+
+	expect := func(notification string) {
+		t.Helper()
+
+		timeout := time.After(3 * time.Second)
+
+		for {
+			select {
+			case got := <-notifications:
+				if got == notification {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Expected %s notification", notification)
+			}
+		}
+	}
+
+	expectNone := func(notification string, d time.Duration) {
+		t.Helper()
+
+		timeout := time.After(d)
+
+		for {
+			select {
+			case got := <-notifications:
+				if got == notification {
+					t.Fatalf("Expected no %s notification", notification)
+				}
+			case <-timeout:
+				return
+			}
+		}
+	}
+
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	t.Setenv("WATCHDOG_USEC", "200000")
+
+	// Not ready, yet alive.
+	readinessFlag := handler.NewReadinessDeterminer("flag")
+
+	livenessFlag := handler.NewReadinessDeterminer("worker")
+	livenessFlag.SetReadiness(true)
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithReadiness(readinessFlag),
+		WithSystemdNotify(),
+		WithSystemdWatchdog(livenessFlag),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	waitReady(t, testServer)
+
+	expect("READY=1")
+
+	// The watchdog is a liveness signal, readiness isn't consulted.
+	expect("WATCHDOG=1")
+
+	// Withheld while a watchdog determiner isn't ready. Skips a notification
+	// possibly sent meanwhile.
+	livenessFlag.SetReadiness(false)
+
+	time.Sleep(50 * time.Millisecond)
+
+	for len(notifications) > 0 {
+		<-notifications
+	}
+
+	expectNone("WATCHDOG=1", 600*time.Millisecond)
+
+	livenessFlag.SetReadiness(true)
+
+	expect("WATCHDOG=1")
+
+	if err := testServer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("STOPPING=1")
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Definitions.
+//////
+
+// ShutdownTaskFunc is a task which runs once the server is shut down, e.g.:
+// flush telemetry, close DB pools, or flush log files. It should respect `ctx`
+// deadline, which is bound to `ShutdownTaskTimeout`.
+type ShutdownTaskFunc func(ctx context.Context) error
+
+// ShutdownTask definition.
+type ShutdownTask struct {
+	// Name of the task, used for reporting.
+	Name string `json:"name" validate:"required"`
+
+	// Func is the task itself.
+	Func ShutdownTaskFunc `json:"-" validate:"required"`
+}
+
+// ShutdownTaskError indicates a shutdown task failed, or timed out.
+type ShutdownTaskError struct {
+	// Name of the task.
+	Name string
+
+	// Err is what made the task fail.
+	Err error
+}
+
+// Error interface implementation.
+func (e *ShutdownTaskError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Err)
+}
+
+// Unwrap interface implementation.
+func (e *ShutdownTaskError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownTasksError lists all shutdown tasks which failed, or timed out.
+type ShutdownTasksError []*ShutdownTaskError
+
+// Error interface implementation.
+func (e ShutdownTasksError) Error() string {
+	msgs := make([]string, 0, len(e))
+
+	for _, taskErr := range e {
+		msgs = append(msgs, taskErr.Error())
+	}
+
+	return fmt.Sprintf("shutdown tasks failed: %s", strings.Join(msgs, "; "))
+}
+
+//////
+// Helpers.
+//////
+
+// Runs `task`, but doesn't wait it beyond `ctx` deadline.
+func runShutdownTask(ctx context.Context, task ShutdownTask) error {
+	taskErr := make(chan error, 1)
+
+	go func() {
+		taskErr <- task.Func(ctx)
+	}()
+
+	select {
+	case err := <-taskErr:
+		return err
+	case <-ctx.Done():
+		return customerror.NewFailedToError(
+			"finish shutdown task, timed out",
+			customerror.WithError(ctx.Err()),
+		)
+	}
+}
+
+// Runs `tasks`, in order, or in parallel, under the `ctx` budget. It returns
+// as soon as all tasks finish, reporting which failed, or timed out.
+func runShutdownTasks(ctx context.Context, parallel bool, tasks ...ShutdownTask) error {
+	errs := make([]error, len(tasks))
+
+	if parallel {
+		var wg sync.WaitGroup
+
+		for i, task := range tasks {
+			wg.Add(1)
+
+			go func(i int, task ShutdownTask) {
+				defer wg.Done()
+
+				errs[i] = runShutdownTask(ctx, task)
+			}(i, task)
+		}
+
+		wg.Wait()
+	} else {
+		for i, task := range tasks {
+			errs[i] = runShutdownTask(ctx, task)
+		}
+	}
+
+	var tasksErr ShutdownTasksError
+
+	for i, err := range errs {
+		if err != nil {
+			tasksErr = append(tasksErr, &ShutdownTaskError{Name: tasks[i].Name, Err: err})
+		}
+	}
+
+	if len(tasksErr) > 0 {
+		return tasksErr
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_runShutdownTasks(t *testing.T) {
+	errFlush := errors.New("flush failed")
+
+	type args struct {
+		parallel bool
+		timeout  time.Duration
+		delays   []time.Duration
+		errs     []error
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantOrder   []string
+		wantFailed  []string
+		wantTimeout []string
+	}{
+		{
+			name: "Should work - in order",
+			args: args{
+				timeout: time.Second,
+				delays:  []time.Duration{20 * time.Millisecond, 0, 0},
+				errs:    []error{nil, nil, nil},
+			},
+			wantOrder: []string{"task-0", "task-1", "task-2"},
+		},
+		{
+			name: "Should work - in parallel",
+			args: args{
+				parallel: true,
+				timeout:  time.Second,
+				delays:   []time.Duration{100 * time.Millisecond, 0, 0},
+				errs:     []error{nil, nil, nil},
+			},
+		},
+		{
+			name: "Should work - reports failed",
+			args: args{
+				timeout: time.Second,
+				delays:  []time.Duration{0, 0},
+				errs:    []error{nil, errFlush},
+			},
+			wantFailed: []string{"task-1"},
+		},
+		{
+			name: "Should work - reports timed out",
+			args: args{
+				parallel: true,
+				timeout:  100 * time.Millisecond,
+				delays:   []time.Duration{0, time.Second},
+				errs:     []error{nil, nil},
+			},
+			wantFailed:  []string{"task-1"},
+			wantTimeout: []string{"task-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order []string
+
+			orderMutex := sync.Mutex{}
+
+			tasks := []ShutdownTask{}
+
+			for i := range tt.args.delays {
+				name := fmt.Sprintf("task-%d", i)
+				delay := tt.args.delays[i]
+				err := tt.args.errs[i]
+
+				tasks = append(tasks, ShutdownTask{
+					Name: name,
+					Func: func(ctx context.Context) error {
+						time.Sleep(delay)
+
+						orderMutex.Lock()
+						order = append(order, name)
+						orderMutex.Unlock()
+
+						return err
+					},
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.args.timeout)
+			defer cancel()
+
+			now := time.Now()
+
+			err := runShutdownTasks(ctx, tt.args.parallel, tasks...)
+
+			if time.Since(now) > tt.args.timeout+50*time.Millisecond {
+				t.Fatalf("Expected tasks to respect %s budget", tt.args.timeout)
+			}
+
+			if tt.wantOrder != nil && !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Fatalf("Expected %v got %v", tt.wantOrder, order)
+			}
+
+			if tt.wantFailed == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			var tasksErr ShutdownTasksError
+
+			if !errors.As(err, &tasksErr) {
+				t.Fatalf("Expected ShutdownTasksError got %v", err)
+			}
+
+			failed := []string{}
+			timedOut := []string{}
+
+			for _, taskErr := range tasksErr {
+				failed = append(failed, taskErr.Name)
+
+				if isTimeoutError(taskErr) {
+					timedOut = append(timedOut, taskErr.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Fatalf("Expected %v to fail got %v", tt.wantFailed, failed)
+			}
+
+			if tt.wantTimeout != nil && !reflect.DeepEqual(timedOut, tt.wantTimeout) {
+				t.Fatalf("Expected %v to time out got %v", tt.wantTimeout, timedOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+//////
+// Consts, vars, and types.
+//////
+
+// State of the server lifecycle. A server goes through, in order: `StateNew`,
+// `StateStarting`, `StateServing`, `StateDraining`, and `StateStopped`. A
+// server which fails to start goes straight to `StateStopped`.
+type State int
+
+const (
+	// StateNew indicates the server was created, but not started.
+	StateNew State = iota
+
+	// StateStarting indicates the server is about to listen.
+	StateStarting
+
+	// StateServing indicates the server is accepting connections.
+	StateServing
+
+	// StateDraining indicates the server is shutting down, and waiting for
+	// in-flight requests, and shutdown tasks to finish.
+	StateDraining
+
+	// StateStopped indicates the server is stopped.
+	StateStopped
+)
+
+// StateChangeFunc is called on every server lifecycle state transition.
+type StateChangeFunc func(from, to State)
+
+// String interface implementation.
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateServing:
+		return "serving"
+	case StateDraining:
+		return "draining"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+//////
+// Server.
+//////
+
+// State returns the server lifecycle state.
+func (s *Server) State() State {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.state
+}
+
+// ShuttingDown reports whether shutdown started, i.e.: the server is draining,
+// or stopped. See `handler.Stop`.
+func (s *Server) ShuttingDown() bool {
+	return s.State() >= StateDraining
+}
+
+// OnStateChange registers `fn` to be called on every server lifecycle state
+// transition. Calls happen in the transition order, and shouldn't block.
+func (s *Server) OnStateChange(fn StateChangeFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.stateChangeFuncs = append(s.stateChangeFuncs, fn)
+}
+
+// Transitions the server lifecycle state to `to`, notifying subscribers. The
+// lifecycle only moves forward, e.g.: a draining server can't go back serving.
+// The server is only considered ready while serving.
+func (s *Server) setState(to State) {
+	s.m.Lock()
+
+	from := s.state
+
+	if to <= from {
+		s.m.Unlock()
+
+		return
+	}
+
+	s.state = to
+
+	stateChangeFuncs := append([]StateChangeFunc{}, s.stateChangeFuncs...)
+
+	s.m.Unlock()
+
+	if to == StateServing {
+		s.stateDeterminer.SetReadiness(true)
+	} else {
+		s.stateDeterminer.SetReadinessWithReason(false, "server is "+to.String())
+	}
+
+	s.GetLogger().Tracelnf("Server state changed from %s to %s", from, to)
+
+	for _, fn := range stateChangeFuncs {
+		fn(from, to)
+	}
+}
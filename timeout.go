@@ -0,0 +1,333 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	handler "github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/internal/middleware"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const (
+	// TimeoutModeBuffer buffers responses, replying `ErrRequesTimeout` once
+	// the request times out. Like `http.TimeoutHandler`, it breaks streaming.
+	TimeoutModeBuffer = "buffer"
+
+	// TimeoutModeContext sets the request context deadline, without
+	// buffering responses. Handlers need to honor it. If nothing was written
+	// once the request times out, it replies `ErrRequesTimeout`.
+	TimeoutModeContext = "context"
+)
+
+// Requests timed out metric label for requests which match no route.
+const unmatchedRoute = "unmatched"
+
+//////
+// Definitions.
+//////
+
+// Timeout settings of a route.
+type routeTimeout struct {
+	// Route path template, or the request path if no route matches.
+	route string
+
+	// Whether a route matched, see `unmatchedRoute`.
+	matched bool
+
+	// Streaming routes don't time out.
+	streaming bool
+
+	// Zero means no timeout.
+	timeout time.Duration
+}
+
+// Request timeout settings. They're swappable at runtime, see `Reload`.
+type requestTimeoutSettings struct {
+	mode    string
+	timeout time.Duration
+}
+
+// Buffers a response, until the request either finishes, or times out.
+type bufferedWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+
+	code        int
+	err         error
+	m           sync.Mutex
+	wroteHeader bool
+}
+
+// Header interface implementation.
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+// Write interface implementation.
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.m.Lock()
+	defer bw.m.Unlock()
+
+	if bw.err != nil {
+		return 0, bw.err
+	}
+
+	if !bw.wroteHeader {
+		bw.writeHeaderLocked(http.StatusOK)
+	}
+
+	return bw.buf.Write(b)
+}
+
+// WriteHeader interface implementation.
+func (bw *bufferedWriter) WriteHeader(code int) {
+	bw.m.Lock()
+	defer bw.m.Unlock()
+
+	bw.writeHeaderLocked(code)
+}
+
+// Records the status code, once.
+func (bw *bufferedWriter) writeHeaderLocked(code int) {
+	if bw.err != nil || bw.wroteHeader {
+		return
+	}
+
+	bw.wroteHeader = true
+	bw.code = code
+}
+
+// Keeps track of whether a response was written, without buffering it.
+type timeoutWriter struct {
+	http.ResponseWriter
+
+	wrote bool
+}
+
+// WriteHeader interface implementation.
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.wrote = true
+
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// Write interface implementation.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.wrote = true
+
+	return tw.ResponseWriter.Write(b)
+}
+
+// Flush interface implementation.
+func (tw *timeoutWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.wrote = true
+
+		flusher.Flush()
+	}
+}
+
+// Hijack interface implementation.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	tw.wrote = true
+
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying response writer.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+//////
+// Helpers.
+//////
+
+// Lifts the connection read, and write deadlines, where supported.
+func liftDeadlines(w http.ResponseWriter) {
+	if d, ok := w.(interface{ SetReadDeadline(time.Time) error }); ok {
+		_ = d.SetReadDeadline(time.Time{})
+	}
+
+	if d, ok := w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		_ = d.SetWriteDeadline(time.Time{})
+	}
+}
+
+//////
+// Server.
+//////
+
+// Returns the timeout settings for `r`, based on the matched route.
+func (s *Server) routeTimeout(router *mux.Router, r *http.Request, defaultTimeout time.Duration) routeTimeout {
+	rt := routeTimeout{route: r.URL.Path, timeout: defaultTimeout}
+
+	var match mux.RouteMatch
+
+	if !router.Match(r, &match) || match.Route == nil {
+		return rt
+	}
+
+	if tpl, err := match.Route.GetPathTemplate(); err == nil {
+		rt.matched = true
+		rt.route = tpl
+	}
+
+	if v, ok := s.routeTimeouts.Load(match.Route); ok {
+		h := v.(handler.Handler)
+
+		rt.streaming = h.Streaming
+		rt.timeout = h.Timeout
+	}
+
+	return rt
+}
+
+// Reports `r` timed out: logs it naming the route, counts it per route, or as
+// `unmatchedRoute`, and replies with `ErrRequesTimeout`, unless `replied`.
+func (s *Server) requestTimedOut(w http.ResponseWriter, r *http.Request, rt routeTimeout, replied bool) {
+	s.GetLogger().Warnlnf(
+		"Request timed out after %s, route %s %s, request id %s",
+		rt.timeout, r.Method, rt.route, handler.RequestID(r),
+	)
+
+	// Request paths are unbounded, e.g.: scanners.
+	label := unmatchedRoute
+
+	if rt.matched {
+		label = rt.route
+	}
+
+	s.requestTimeouts.Add(label, 1)
+
+	if !replied {
+		handler.WriteError(w, r, ErrRequesTimeout)
+	}
+}
+
+// Runs `next` buffering its response, replying with `ErrRequesTimeout` if it
+// doesn't finish in time. It's `http.TimeoutHandler`, rendering errors
+// consistently.
+func (s *Server) serveBuffered(w http.ResponseWriter, r *http.Request, next http.Handler, rt routeTimeout) {
+	ctx, cancel := context.WithTimeout(r.Context(), rt.timeout)
+	defer cancel()
+
+	r = r.WithContext(ctx)
+
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+
+	bw := &bufferedWriter{header: make(http.Header)}
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+
+		next.ServeHTTP(bw, r)
+
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+		bw.m.Lock()
+		defer bw.m.Unlock()
+
+		dst := w.Header()
+
+		for k, vv := range bw.header {
+			dst[k] = vv
+		}
+
+		if !bw.wroteHeader {
+			bw.code = http.StatusOK
+		}
+
+		w.WriteHeader(bw.code)
+
+		_, _ = w.Write(bw.buf.Bytes())
+	case <-ctx.Done():
+		bw.m.Lock()
+		defer bw.m.Unlock()
+
+		bw.err = ctx.Err()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			bw.err = http.ErrHandlerTimeout
+
+			s.requestTimedOut(w, r, rt, false)
+
+			return
+		}
+
+		// Client is gone.
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Runs `next` with a context deadline, without buffering its response. If
+// nothing was written once it times out, replies with `ErrRequesTimeout`.
+func (s *Server) serveWithDeadline(w http.ResponseWriter, r *http.Request, next http.Handler, rt routeTimeout) {
+	ctx, cancel := context.WithTimeout(r.Context(), rt.timeout)
+	defer cancel()
+
+	tw := &timeoutWriter{ResponseWriter: w}
+
+	next.ServeHTTP(tw, r.WithContext(ctx))
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.requestTimedOut(w, r, rt, tw.wrote)
+	}
+}
+
+// Returns the current request timeout settings.
+func (s *Server) requestTimeoutSettings() requestTimeoutSettings {
+	return s.requestTimeout.Load().(requestTimeoutSettings)
+}
+
+// Wraps `router` enforcing per-route timeouts, `defaultTimeout` for routes
+// without one, according to `RequestTimeoutMode`. Streaming routes are exempt.
+// Settings are read per request, so they can change at runtime.
+func (s *Server) timeoutHandler(router *mux.Router, defaultTimeout func() time.Duration) http.Handler {
+	return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := s.routeTimeout(router, r, defaultTimeout())
+
+		switch {
+		case rt.streaming:
+			liftDeadlines(w)
+
+			router.ServeHTTP(w, r)
+		case rt.timeout == 0:
+			router.ServeHTTP(w, r)
+		case s.requestTimeoutSettings().mode == TimeoutModeContext:
+			s.serveWithDeadline(w, r, router, rt)
+		default:
+			s.serveBuffered(w, r, router, rt)
+		}
+	}))
+}
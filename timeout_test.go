@@ -0,0 +1,273 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/metric"
+)
+
+// Writes, and flushes `chunks`, every `interval`, then waits for the request
+// to be done.
+func streamHandler(path string, chunks int, interval time.Duration) handler.Handler {
+	return handler.Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < chunks; i++ {
+				fmt.Fprintf(w, "chunk-%d,", i)
+
+				if flusher, ok := w.(http.Flusher); ok {
+					flusher.Flush()
+				}
+
+				time.Sleep(interval)
+			}
+
+			<-r.Context().Done()
+		}),
+		Method: http.MethodGet,
+		Path:   path,
+	}
+}
+
+// Sleeps `d`, or until the request is done.
+func sleepHandler(path string, d time.Duration) handler.Handler {
+	return handler.Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(d):
+				fmt.Fprint(w, http.StatusText(http.StatusOK))
+			case <-r.Context().Done():
+			}
+		}),
+		Method: http.MethodGet,
+		Path:   path,
+	}
+}
+
+func TestNew_requestTimeout(t *testing.T) {
+	// Overrides the server request timeout.
+	slowOverride := sleepHandler("/slow-override", 1500*time.Millisecond)
+	slowOverride.Timeout = 3 * time.Second
+
+	// Exempt from request timeouts.
+	stream := streamHandler("/stream", 3, 600*time.Millisecond)
+	stream.Streaming = true
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(
+			sleepHandler("/slow", 1500*time.Millisecond),
+			slowOverride,
+			stream,
+			streamHandler("/stream-context", 1, 0),
+		),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+		WithRequestTimeoutMode(TimeoutModeContext),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	t.Run("Should fail - server timeout, nothing written", func(t *testing.T) {
+		callAndExpect(t, addr, "slow", http.StatusRequestTimeout, ErrRequesTimeout.Error())
+	})
+
+	t.Run("Should work - per-handler timeout", func(t *testing.T) {
+		callAndExpect(t, addr, "slow-override", http.StatusOK, http.StatusText(http.StatusOK))
+	})
+
+	t.Run("Should work - context mode, response isn't buffered", func(t *testing.T) {
+		callAndExpect(t, addr, "stream-context", http.StatusOK, "chunk-0,")
+	})
+
+	t.Run("Should work - streaming, no timeout", func(t *testing.T) {
+		// Client gives up, otherwise it would wait forever.
+		ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/stream", addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		body := make([]byte, len("chunk-0,chunk-1,chunk-2,"))
+
+		if _, err := io.ReadFull(resp.Body, body); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != "chunk-0,chunk-1,chunk-2," {
+			t.Fatalf("Expected %s got %s", "chunk-0,chunk-1,chunk-2,", body)
+		}
+	})
+}
+
+func TestNew_requestTimeoutBuffer(t *testing.T) {
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(streamHandler("/stream-buffer", 1, 0)),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	// Buffered response is discarded once the request times out.
+	callAndExpect(t, waitReady(t, testServer), "stream-buffer", http.StatusRequestTimeout, ErrRequesTimeout.Error())
+}
+
+func TestNew_requestTimeoutError(t *testing.T) {
+	const name = "timeout-server"
+
+	// Requests matching no route time out too.
+	router := mux.NewRouter()
+	router.NotFoundHandler = sleepHandler("", 1500*time.Millisecond).Handler
+
+	testServer, err := New(name, "127.0.0.1:0",
+		WithRouter(router),
+		WithHandlers(sleepHandler("/slow", 1500*time.Millisecond)),
+		WithMetrics(),
+		WithTimeout(3*time.Second, 500*time.Millisecond, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	get := func(t *testing.T, accept string, v interface{}) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/slow", addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Accept", accept)
+		req.Header.Set(handler.RequestIDHeader, "test-request-id")
+
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusRequestTimeout {
+			t.Fatalf("Expected %d got %d", http.StatusRequestTimeout, resp.StatusCode)
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+
+		return resp
+	}
+
+	t.Run("Should work - JSON", func(t *testing.T) {
+		var body handler.ErrorResponse
+
+		resp := get(t, MIMEJSON, &body)
+
+		if resp.Header.Get(handler.RequestIDHeader) != "test-request-id" {
+			t.Fatalf("Expected request id to be echoed, got %s", resp.Header.Get(handler.RequestIDHeader))
+		}
+
+		expected := handler.ErrorResponse{
+			Message:    "failed to finish request, timed out",
+			RequestID:  "test-request-id",
+			StatusCode: http.StatusRequestTimeout,
+		}
+
+		if !reflect.DeepEqual(body, expected) {
+			t.Fatalf("Expected %+v got %+v", expected, body)
+		}
+	})
+
+	t.Run("Should work - problem JSON", func(t *testing.T) {
+		var body handler.ProblemResponse
+
+		resp := get(t, handler.MIMEProblemJSON, &body)
+
+		if resp.Header.Get("Content-Type") != handler.MIMEProblemJSON {
+			t.Fatalf("Expected %s got %s", handler.MIMEProblemJSON, resp.Header.Get("Content-Type"))
+		}
+
+		if body.Status != http.StatusRequestTimeout || body.Instance != "/slow" || body.RequestID != "test-request-id" {
+			t.Fatalf("Unexpected problem %+v", body)
+		}
+	})
+
+	t.Run("Should work - timeouts metric", func(t *testing.T) {
+		timeouts, ok := metric.Get(name + "_request_timeouts").(*metric.Map)
+		if !ok {
+			t.Fatal("Expected timeouts metric to be published")
+		}
+
+		if count := timeouts.Get("/slow"); count == nil || count.String() != "2" {
+			t.Fatalf("Expected %d timeouts got %v", 2, count)
+		}
+	})
+
+	t.Run("Should work - timeouts metric, unmatched routes share a label", func(t *testing.T) {
+		callAndExpect(t, addr, "scanner-probe", http.StatusRequestTimeout, ErrRequesTimeout.Error())
+
+		timeouts := metric.Get(name + "_request_timeouts").(*metric.Map)
+
+		if timeouts.Get("/scanner-probe") != nil {
+			t.Fatal("Expected request path not to be a label")
+		}
+
+		if count := timeouts.Get(unmatchedRoute); count == nil || count.String() != "1" {
+			t.Fatalf("Expected %d timeouts got %v", 1, count)
+		}
+	})
+
+	t.Run("Should work - timeouts metric, shared by servers with the same name", func(t *testing.T) {
+		sameName, err := New(name, "127.0.0.1:0", WithMetrics())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sameName.(*Server).requestTimeouts != testServer.(*Server).requestTimeouts {
+			t.Fatal("Expected timeouts metric to be shared")
+		}
+	})
+}
@@ -0,0 +1,208 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/saucelabs/customerror"
+	"github.com/saucelabs/sypl"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const defaultTLSReloadInterval = 10 * time.Second
+
+//////
+// Definitions.
+//////
+
+// TLS settings. Certificates are reloaded from disk once they change, or on
+// `Reload`, without restarting the server.
+type TLS struct {
+	// CertFile is the path to the PEM encoded server certificate.
+	CertFile string `json:"cert_file" validate:"required,file"`
+
+	// KeyFile is the path to the PEM encoded server private key.
+	KeyFile string `json:"key_file" validate:"required,file"`
+
+	// ClientCAFiles are paths to PEM encoded CAs used to verify clients. If
+	// set, clients are required to present a valid certificate (mutual TLS),
+	// default: none.
+	ClientCAFiles []string `json:"client_ca_files" validate:"omitempty,dive,file"`
+
+	// ReloadInterval is how often files are checked for changes, default: 10s.
+	// Set to `0` to disable that.
+	ReloadInterval time.Duration `json:"reload_interval" validate:"gte=0"`
+}
+
+// Loads, and hot reloads certificates from disk.
+type certReloader struct {
+	certFile      string
+	keyFile       string
+	clientCAFiles []string
+
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	m         sync.RWMutex
+}
+
+//////
+// certReloader.
+//////
+
+// Returns all watched files.
+func (c *certReloader) files() []string {
+	return append([]string{c.certFile, c.keyFile}, c.clientCAFiles...)
+}
+
+// Reloads all files. If any fails to load, the current ones are kept.
+func (c *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return customerror.NewFailedToError("load TLS certificate", customerror.WithError(err))
+	}
+
+	var clientCAs *x509.CertPool
+
+	if len(c.clientCAFiles) > 0 {
+		clientCAs = x509.NewCertPool()
+
+		for _, caFile := range c.clientCAFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return customerror.NewFailedToError("load client CA", customerror.WithError(err))
+			}
+
+			if !clientCAs.AppendCertsFromPEM(pem) {
+				return customerror.NewInvalidError("client CA " + caFile + ", no PEM certificate")
+			}
+		}
+	}
+
+	modTimes := map[string]time.Time{}
+
+	for _, f := range c.files() {
+		if info, err := os.Stat(f); err == nil {
+			modTimes[f] = info.ModTime()
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTimes = modTimes
+
+	return nil
+}
+
+// Verifies if any file changed since last reload.
+func (c *certReloader) changed() bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+
+		if !info.ModTime().Equal(c.modTimes[f]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Polls files for changes every `interval` until `ctx` is done, reloading them.
+func (c *certReloader) watch(ctx context.Context, interval time.Duration, l sypl.ISypl) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+
+			if err := c.reload(); err != nil {
+				l.Errorlnf("TLS certificates changed, but failed to reload, keeping current ones. %s", err)
+
+				continue
+			}
+
+			l.Debuglnf("TLS certificates reloaded")
+		}
+	}
+}
+
+// Returns the TLS configuration based on the current certificates.
+func (c *certReloader) config() *tls.Config {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.m.RLock()
+			defer c.m.RUnlock()
+
+			return c.cert, nil
+		},
+	}
+
+	if c.clientCAs != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = c.clientCAs
+	}
+
+	return cfg
+}
+
+// Returns a TLS configuration which always uses the current certificates.
+func (c *certReloader) tlsConfig() *tls.Config {
+	cfg := c.config()
+
+	// Per-connection configuration allows client CAs to be reloaded.
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return c.config(), nil
+	}
+
+	return cfg
+}
+
+//////
+// Factory.
+//////
+
+// Creates a certificate reloader, loading certificates for the first time.
+func newCertReloader(t *TLS) (*certReloader, error) {
+	c := &certReloader{
+		certFile:      t.CertFile,
+		keyFile:       t.KeyFile,
+		clientCAFiles: t.ClientCAFiles,
+	}
+
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+// Test certificate, and its key.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Issues a certificate signed by `parent`, or self-signed if `parent` is nil.
+func issueTestCert(t *testing.T, commonName string, serial int64, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	signerCert, signerKey := template, key
+
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+// Writes the certificate, and its key as PEM files, returning their paths.
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+// Returns a HTTPS client trusting `ca`, optionally presenting `clientCert`.
+func tlsClient(ca *testCert, clientCert *testCert) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	cfg := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{clientCert.cert.Raw},
+			PrivateKey:  clientCert.key,
+		}}
+	}
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: cfg},
+	}
+}
+
+func TestNew_tls(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := issueTestCert(t, "test-ca", 1, true, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+
+	certFile, keyFile := issueTestCert(t, "test-server", 2, false, ca).write(t, dir, "server")
+
+	clientCert := issueTestCert(t, "test-client", 3, false, ca)
+
+	// Replies with the client identity.
+	identityHandler := handler.Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, handler.PeerIdentity(r))
+		}),
+		Method: http.MethodGet,
+		Path:   "/identity",
+	}
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(identityHandler),
+		WithTLS(certFile, keyFile),
+		WithClientCA(caFile),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	addr := waitReady(t, testServer)
+
+	get := func(client *http.Client) (*http.Response, string, error) {
+		resp, err := client.Get(fmt.Sprintf("https://%s/identity", addr))
+		if err != nil {
+			return nil, "", err
+		}
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+
+		return resp, string(body), err
+	}
+
+	t.Run("Should fail - mTLS, no client certificate", func(t *testing.T) {
+		if _, _, err := get(tlsClient(ca, nil)); err == nil {
+			t.Fatal("Expected request without client certificate to fail")
+		}
+	})
+
+	t.Run("Should work - mTLS, peer identity", func(t *testing.T) {
+		_, body, err := get(tlsClient(ca, clientCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body != "test-client" {
+			t.Fatalf("Expected %s got %s", "test-client", body)
+		}
+	})
+
+	t.Run("Should work - reload", func(t *testing.T) {
+		// Replaces the server certificate.
+		issueTestCert(t, "test-server", 4, false, ca).write(t, dir, "server")
+
+		if err := testServer.Reload(); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, _, err := get(tlsClient(ca, clientCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 4 {
+			t.Fatalf("Expected reloaded certificate serial %d got %d", 4, serial)
+		}
+	})
+}
+
+func TestNew_tlsInvalid(t *testing.T) {
+	if _, err := New(serverName, "127.0.0.1:0", WithClientCA("ca.crt")); err == nil {
+		t.Fatal("Expected client CA without certificate to fail")
+	}
+
+	if _, err := New(serverName, "127.0.0.1:0", WithTLS("missing.crt", "missing.key")); err == nil {
+		t.Fatal("Expected missing certificate files to fail")
+	}
+}
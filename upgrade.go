@@ -0,0 +1,271 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package webserver
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/saucelabs/customerror"
+)
+
+//////
+// Consts, and vars.
+//////
+
+const (
+	defaultUpgradeTimeout = 10 * time.Second
+
+	// Set, in the new process, to the address of the handed off listener.
+	envUpgradeAddress = "WEBSERVER_UPGRADE_ADDRESS"
+
+	// Set, in the new process, to the address of the handed off admin
+	// listener.
+	envUpgradeAdminAddress = "WEBSERVER_UPGRADE_ADMIN_ADDRESS"
+
+	// File descriptor of the handed off listener, in the new process.
+	upgradeListenerFD = 3
+
+	// File descriptor the new process writes to once it's ready.
+	upgradeReadyFD = 4
+
+	// File descriptor of the handed off admin listener, in the new process.
+	upgradeAdminListenerFD = 5
+)
+
+//////
+// Helpers.
+//////
+
+// Returns the listener handed off by the parent process as `fd`, during an
+// upgrade, if `env` is set to `address`.
+func inheritedListener(env string, fd uintptr, address string) (net.Listener, bool, error) {
+	if address == "" || os.Getenv(env) != address {
+		return nil, false, nil
+	}
+
+	// It's only inherited once.
+	os.Unsetenv(env)
+
+	f := os.NewFile(fd, "upgrade-listener")
+	defer f.Close()
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, false, customerror.NewFailedToError("use inherited listener", customerror.WithError(err))
+	}
+
+	// This process owns the Unix domain socket now.
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(true)
+	}
+
+	return listener, true, nil
+}
+
+// Returns the listener handed off by the parent process, during an upgrade,
+// otherwise listens on `address`. It also reports whether it was inherited.
+func acquireListener(env string, fd uintptr, address string, mode os.FileMode) (net.Listener, bool, error) {
+	listener, inherited, err := inheritedListener(env, fd, address)
+	if err != nil || inherited {
+		return listener, inherited, err
+	}
+
+	listener, err = listen(address, mode)
+
+	return listener, false, err
+}
+
+// Returns the file of `listener`, to be handed off.
+func listenerFile(listener net.Listener) (*os.File, error) {
+	filer, ok := listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, customerror.NewFailedToError("upgrade, server isn't listening on a socket")
+	}
+
+	f, err := filer.File()
+	if err != nil {
+		return nil, customerror.NewFailedToError("upgrade, get listener file", customerror.WithError(err))
+	}
+
+	return f, nil
+}
+
+// Tells the parent process, during an upgrade, this process is ready.
+func notifyUpgradeParent() error {
+	f := os.NewFile(upgradeReadyFD, "upgrade-ready")
+	defer f.Close()
+
+	if _, err := f.Write([]byte{1}); err != nil {
+		return customerror.NewFailedToError("notify parent process about the upgrade", customerror.WithError(err))
+	}
+
+	return nil
+}
+
+// Returns the current environment, without `keys`.
+func environWithout(keys ...string) []string {
+	env := []string{}
+
+next:
+	for _, kv := range os.Environ() {
+		for _, key := range keys {
+			if strings.HasPrefix(kv, key+"=") {
+				continue next
+			}
+		}
+
+		env = append(env, kv)
+	}
+
+	return env
+}
+
+//////
+// Server.
+//////
+
+// Upgrade performs a zero-downtime binary upgrade: the current binary is
+// re-executed, with the same arguments, handing off the listener. Once the new
+// process is ready, this server gracefully shuts down. If the new process
+// doesn't get ready in time, see `UpgradeTimeout`, it's killed, and this
+// server keeps serving. Once upgraded, `Run` returns `nil`.
+//
+// NOTE: Only one server per process can be upgraded.
+func (s *Server) Upgrade() error {
+	s.m.Lock()
+	listener := s.listener
+	adminListener := s.adminListener
+	s.m.Unlock()
+
+	cmd, readyReader, err := s.startUpgrade(listener, adminListener)
+	if err != nil {
+		return err
+	}
+
+	defer readyReader.Close()
+
+	s.GetLogger().Debuglnf(
+		"Upgrading, waiting up to %s for new process (%d) to be ready",
+		s.UpgradeTimeout, cmd.Process.Pid,
+	)
+
+	if err := s.waitUpgrade(cmd, readyReader); err != nil {
+		return err
+	}
+
+	s.GetLogger().Debuglnf("Upgraded, new process (%d) is ready, gracefully shutting down", cmd.Process.Pid)
+
+	_ = cmd.Process.Release()
+
+	// The new process owns Unix domain sockets now.
+	for _, l := range []net.Listener{listener, adminListener} {
+		if unixListener, ok := l.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+
+	// Handed off, it's a clean stop, see `Run`.
+	s.m.Lock()
+	s.upgraded = true
+	s.m.Unlock()
+
+	return s.Shutdown(context.Background())
+}
+
+// Starts the new process, handing off the listeners, and returns it, and the
+// pipe it closes once ready, see `waitUpgrade`.
+func (s *Server) startUpgrade(listener, adminListener net.Listener) (*exec.Cmd, *os.File, error) {
+	lFile, err := listenerFile(listener)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer lFile.Close()
+
+	env := append(environWithout(envUpgradeAddress, envUpgradeAdminAddress), envUpgradeAddress+"="+s.Address)
+
+	// The ready pipe is set once created.
+	extraFiles := []*os.File{lFile, nil}
+
+	if adminListener != nil {
+		adminFile, err := listenerFile(adminListener)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer adminFile.Close()
+
+		env = append(env, envUpgradeAdminAddress+"="+s.Admin.Address)
+		extraFiles = append(extraFiles, adminFile)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, nil, customerror.NewFailedToError("upgrade, create pipe", customerror.WithError(err))
+	}
+
+	// Only the new process should hold it, so its exit is noticed.
+	defer readyWriter.Close()
+
+	extraFiles[1] = readyWriter
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyReader.Close()
+
+		return nil, nil, customerror.NewFailedToError("upgrade, find executable", customerror.WithError(err))
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...) //nolint:gosec
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extraFiles
+
+	if err := cmd.Start(); err != nil {
+		readyReader.Close()
+
+		return nil, nil, customerror.NewFailedToError("upgrade, start new process", customerror.WithError(err))
+	}
+
+	return cmd, readyReader, nil
+}
+
+// Waits for the new process to be ready, see `UpgradeTimeout`, otherwise it's
+// killed.
+func (s *Server) waitUpgrade(cmd *exec.Cmd, readyReader *os.File) error {
+	childReady := make(chan error, 1)
+
+	go func() {
+		// Fails with EOF if the new process exits before being ready.
+		_, err := readyReader.Read(make([]byte, 1))
+
+		childReady <- err
+	}()
+
+	var err error
+
+	select {
+	case err = <-childReady:
+		if err != nil {
+			err = customerror.NewFailedToError("upgrade, new process exited before being ready", customerror.WithError(err))
+		}
+	case <-time.After(s.UpgradeTimeout):
+		err = customerror.NewFailedToError("upgrade, new process didn't get ready in time")
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+
+	return err
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package webserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/saucelabs/webserver/handler"
+)
+
+// Not a real test. It's the upgraded process, see `TestServer_Upgrade`.
+func TestHelperUpgradeProcess(t *testing.T) {
+	if os.Getenv("WEBSERVER_TEST_UPGRADE") != "1" {
+		t.Skip("helper process")
+	}
+
+	// Replies with the process PID.
+	pidHandler := handler.Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, os.Getpid())
+		}),
+		Method: http.MethodGet,
+		Path:   "/pid",
+	}
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(pidHandler),
+		WithSignals(syscall.SIGTERM),
+		WithUpgradeSignals(syscall.SIGUSR2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		<-testServer.Ready()
+
+		_ = os.WriteFile(os.Getenv("WEBSERVER_TEST_ADDRESS_FILE"), []byte(testServer.Addr()), 0o600)
+	}()
+
+	// Upgraded, or stopped by a signal, it's a clean stop, checked by the
+	// parent process' exit code.
+	if err := testServer.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_Upgrade(t *testing.T) {
+	addressFile := filepath.Join(t.TempDir(), "address")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperUpgradeProcess$")
+	cmd.Env = append(os.Environ(), "WEBSERVER_TEST_UPGRADE=1", "WEBSERVER_TEST_ADDRESS_FILE="+addressFile)
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	var addr []byte
+
+	for i := 0; i < 50 && len(addr) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+
+		addr, _ = os.ReadFile(addressFile)
+	}
+
+	getPID := func() int {
+		t.Helper()
+
+		resp, err := c.Get(fmt.Sprintf("http://%s/pid", addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pid, err := strconv.Atoi(string(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pid
+	}
+
+	if pid := getPID(); pid != cmd.Process.Pid {
+		t.Fatalf("Expected %d got %d", cmd.Process.Pid, pid)
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests never fail, during, and after the upgrade.
+	for i := 0; i < 100; i++ {
+		pid := getPID()
+
+		if pid != cmd.Process.Pid {
+			// Stops the upgraded process.
+			defer func() {
+				_ = syscall.Kill(pid, syscall.SIGTERM)
+			}()
+
+			// Old process gracefully exits, `Run` returning `nil`.
+			if err := cmd.Wait(); err != nil {
+				t.Fatal(err)
+			}
+
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatal("Expected new process to serve requests")
+}
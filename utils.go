@@ -10,13 +10,19 @@ import (
 	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/saucelabs/customerror"
 	handler "github.com/saucelabs/webserver/handler"
+	"github.com/saucelabs/webserver/metric"
 )
 
-// Adds a `Handler` to a `Router`.
-func addHandler(router *mux.Router, handlers ...handler.Handler) {
-	for _, handler := range handlers {
-		router.HandleFunc(handler.Path, handler.Handler).Methods(handler.Method)
+// Adds a `Handler` to a `Router`, keeping its timeout settings.
+func (s *Server) addHandler(router *mux.Router, handlers ...handler.Handler) {
+	for _, h := range handlers {
+		route := router.HandleFunc(h.Path, h.Handler).Methods(h.Method)
+
+		if h.Timeout > 0 || h.Streaming {
+			s.routeTimeouts.Store(route, h)
+		}
 	}
 }
 
@@ -30,3 +36,22 @@ func isTimeoutError(err error) bool {
 
 	return false
 }
+
+// Returns the map metric published as `name`, publishing `m` if there's none,
+// e.g.: servers with the same name share it. It fails if `name` is published
+// as another metric type.
+func publishedMap(name string, m *metric.Map) (*metric.Map, error) {
+	published := metric.Get(name)
+
+	if published == nil {
+		metric.Publish(name, m)
+
+		return m, nil
+	}
+
+	if publishedMap, ok := published.(*metric.Map); ok {
+		return publishedMap, nil
+	}
+
+	return nil, customerror.NewInvalidError("metric " + name + ", already published as another type")
+}
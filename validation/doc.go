@@ -3,4 +3,10 @@
 // as not to conflict with the original package. Validation applies the
 // Singleton pattern, and it's safe to be retrieved at any stage of the
 // application flow - it's not coupled to any other component.
+//
+// Custom validations (`RegisterValidation`), struct-level rules
+// (`RegisterStructValidation`), and aliases (`RegisterAlias`) are registered
+// within the shared validator, so they apply to the server, its
+// configuration, and request payloads alike. Invalid fields are reported as a
+// `ValidationError`, with human readable messages.
 package validation
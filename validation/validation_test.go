@@ -0,0 +1,197 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type testTimeout struct {
+	ReadTimeout    time.Duration  `json:"read_timeout" validate:"gte=0"`
+	RequestTimeout time.Duration  `json:"request_timeout" validate:"gte=0,ltfield=ReadTimeout"`
+	WriteTimeout   *time.Duration `json:"write_timeout,omitempty" validate:"omitempty,gtefield=ReadTimeout"`
+}
+
+type testLogging struct {
+	Level string `json:"level" validate:"oneof=error info"`
+}
+
+type testConfig struct {
+	Logging  testLogging    `json:"logging"`
+	Timeout  *testTimeout   `json:"timeout"`
+	Timeouts []*testTimeout `json:"timeouts" validate:"dive"`
+	Name     string         `validate:"required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	writeTimeout := time.Second
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected []FieldError
+	}{
+		{
+			name: "Should work",
+			data: testConfig{
+				Logging: testLogging{Level: "info"},
+				Timeout: &testTimeout{ReadTimeout: 5 * time.Second, RequestTimeout: 2 * time.Second},
+				Name:    "server",
+			},
+		},
+		{
+			name: "Should fail - fields, params named after their JSON name",
+			data: &testConfig{
+				Logging: testLogging{Level: "loud"},
+				Timeout: &testTimeout{ReadTimeout: 5 * time.Second, RequestTimeout: 6 * time.Second},
+				Timeouts: []*testTimeout{
+					{ReadTimeout: 5 * time.Second, WriteTimeout: &writeTimeout},
+				},
+			},
+			expected: []FieldError{
+				{
+					Field:   "logging.level",
+					Rule:    "oneof",
+					Param:   "error info",
+					Value:   "loud",
+					Message: "level must be one of [error info]",
+				},
+				{
+					Field:   "timeout.request_timeout",
+					Rule:    "ltfield",
+					Param:   "read_timeout",
+					Value:   "6s",
+					Message: "request_timeout must be less than read_timeout",
+				},
+				{
+					Field:   "timeouts[0].write_timeout",
+					Rule:    "gtefield",
+					Param:   "read_timeout",
+					Value:   "1s",
+					Message: "write_timeout must be greater than or equal to read_timeout",
+				},
+				{
+					Field:   "Name",
+					Rule:    "required",
+					Value:   "",
+					Message: "Name is a required field",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStruct(tt.data)
+
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			var vE *ValidationError
+
+			if !errors.As(err, &vE) {
+				t.Fatalf("Expected ValidationError got %v", err)
+			}
+
+			if !reflect.DeepEqual(vE.Fields, tt.expected) {
+				t.Fatalf("Expected %+v got %+v", tt.expected, vE.Fields)
+			}
+		})
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	// Tags are unique to this test, as the validator is shared.
+	type release struct {
+		Version string `json:"version" validate:"test_semver"`
+		From    int    `json:"from" validate:"test_port"`
+		To      int    `json:"to" validate:"test_port"`
+	}
+
+	semver := regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+
+	if err := RegisterValidation("test_semver", func(fl FieldLevel) bool {
+		return semver.MatchString(fl.Field().String())
+	}, "{0} must be a semantic version"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterAlias("test_port", "gte=0,lte=65535", "{0} must be a port"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterMessage("test_port_range", "{0} must be greater than {1}"); err != nil {
+		t.Fatal(err)
+	}
+
+	RegisterStructValidation(func(sl StructLevel) {
+		r := sl.Current().Interface().(release)
+
+		if r.To < r.From {
+			sl.ReportError(r.To, "to", "To", "test_port_range", "from")
+		}
+	}, release{})
+
+	tests := []struct {
+		name     string
+		data     release
+		expected []string
+	}{
+		{
+			name:     "Should work",
+			data:     release{Version: "v1.0.0", From: 80, To: 8080},
+			expected: nil,
+		},
+		{
+			name:     "Should fail - custom validation, and alias",
+			data:     release{Version: "1.0", From: 8080, To: 70000},
+			expected: []string{"version must be a semantic version", "to must be a port"},
+		},
+		{
+			name:     "Should fail - struct-level rule",
+			data:     release{Version: "v1.0.0", From: 8080, To: 80},
+			expected: []string{"to must be greater than from"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStruct(tt.data)
+
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			var vE *ValidationError
+
+			if !errors.As(err, &vE) {
+				t.Fatalf("Expected ValidationError got %v", err)
+			}
+
+			if len(vE.Fields) != len(tt.expected) {
+				t.Fatalf("Expected %d fields got %+v", len(tt.expected), vE.Fields)
+			}
+
+			for i, f := range vE.Fields {
+				if f.Message != tt.expected[i] {
+					t.Fatalf("Expected %s got %s", tt.expected[i], f.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2021 The webserver Authors. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	"github.com/saucelabs/customerror"
+)
+
+// Re-usable, cached validator.
+// SEE: https://github.com/go-playground/validator/blob/master/_examples/simple/main.go#L27
+var validatorSingleton *validator.Validate
+
+// Translates validation errors into human readable messages.
+var translator ut.Translator
+
+// Guarantees the validator is set up once.
+var setupOnce sync.Once
+
+// Rules comparing a field against another one of the same struct, named by
+// the rule param.
+var fieldRules = map[string]bool{
+	"eqfield":  true,
+	"nefield":  true,
+	"gtfield":  true,
+	"gtefield": true,
+	"ltfield":  true,
+	"ltefield": true,
+}
+
+// Func validates a field, see `RegisterValidation`.
+type Func = validator.Func
+
+// FieldLevel is the field being validated, see `Func`.
+type FieldLevel = validator.FieldLevel
+
+// StructLevelFunc validates a struct, see `RegisterStructValidation`.
+type StructLevelFunc = validator.StructLevelFunc
+
+// StructLevel is the struct being validated, see `StructLevelFunc`.
+type StructLevel = validator.StructLevel
+
+// FieldError is a field which failed validation.
+type FieldError struct {
+	// Field is the JSON path, e.g.: `timeout.request_timeout`.
+	Field string `json:"field"`
+
+	// Rule is the failed validation rule, e.g.: `ltfield`.
+	Rule string `json:"rule"`
+
+	// Param is the rule parameter, if any, e.g.: `read_timeout`. Fields
+	// compared with, e.g.: `ltfield`, are named after their JSON name.
+	Param string `json:"param,omitempty"`
+
+	// Value is the field value.
+	Value string `json:"value"`
+
+	// Message is the human readable message.
+	Message string `json:"message"`
+}
+
+// ValidationError lists fields which failed validation.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Error interface implementation.
+func (vE *ValidationError) Error() string {
+	messages := make([]string, 0, len(vE.Fields))
+
+	for _, f := range vE.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// Setup validator.
+func setup() {
+	validatorSingleton = validator.New()
+
+	// Fields are named after their JSON name, falling back to the Go one.
+	validatorSingleton.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	translator, _ = ut.New(en.New()).GetTranslator("en")
+
+	// Registration only fails for invalid tags, or translations.
+	_ = enTranslations.RegisterDefaultTranslations(validatorSingleton, translator)
+
+	// Custom validations.
+	_ = registerValidation(
+		"listen_address",
+		isListenAddress,
+		"{0} must be a listen address, e.g.: host:port, unix:/path.sock, or systemd:",
+	)
+}
+
+// Registers a custom validation, and its message.
+func registerValidation(tag string, fn Func, message string) error {
+	if err := validatorSingleton.RegisterValidation(tag, fn); err != nil {
+		return customerror.NewFailedToError("register validation "+tag, customerror.WithError(err))
+	}
+
+	return registerMessage(tag, message)
+}
+
+// Registers the human readable message for `tag`. `{0}` is the field, and
+// `{1}` the rule param.
+func registerMessage(tag, message string) error {
+	if err := validatorSingleton.RegisterTranslation(
+		tag,
+		translator,
+		func(t ut.Translator) error {
+			return t.Add(tag, message, true)
+		},
+		func(t ut.Translator, fe validator.FieldError) string {
+			msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+
+			return msg
+		},
+	); err != nil {
+		return customerror.NewFailedToError("register message for "+tag, customerror.WithError(err))
+	}
+
+	return nil
+}
+
+// isListenAddress validates addresses a server can listen on: `host:port` -
+// like `hostname_port`, but also allows port `0`, an ephemeral port -,
+// `unix:/path.sock`, and `systemd:`, or `systemd:name`.
+func isListenAddress(fl validator.FieldLevel) bool {
+	address := fl.Field().String()
+
+	if strings.HasPrefix(address, "systemd:") {
+		return true
+	}
+
+	if strings.HasPrefix(address, "unix:") {
+		return len(address) > len("unix:")
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return false
+	}
+
+	if host == "" {
+		return true
+	}
+
+	return validatorSingleton.Var(host, "hostname_rfc1123|ip") == nil
+}
+
+// Returns the JSON name of the field `fe` is compared with, e.g.:
+// `ReadTimeout` -> `read_timeout`. Other params are returned as is. `root`
+// is the validated struct type.
+func jsonParam(root reflect.Type, fe validator.FieldError) string {
+	if !fieldRules[fe.Tag()] {
+		return fe.Param()
+	}
+
+	// Walks the Go path, without the top-level struct, and the field itself,
+	// e.g.: `Server.Timeout.RequestTimeout` -> `Timeout`.
+	path := strings.Split(fe.StructNamespace(), ".")
+
+	t := root
+
+	for _, name := range path[1 : len(path)-1] {
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return fe.Param()
+		}
+
+		t = field.Type
+
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fe.Param()
+	}
+
+	field, ok := t.FieldByName(fe.Param())
+	if !ok {
+		return fe.Param()
+	}
+
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+
+	return fe.Param()
+}
+
+// Returns the human readable message for `fe`, where `param` is the rule
+// param, see `jsonParam`.
+func message(fe validator.FieldError, param string) string {
+	if param != fe.Param() {
+		if msg, err := translator.T(fe.Tag(), fe.Field(), param); err == nil {
+			return msg
+		}
+	}
+
+	if msg := fe.Translate(translator); msg != fe.Error() {
+		return msg
+	}
+
+	// No message registered for the rule, see `RegisterMessage`.
+	return fmt.Sprintf("%s failed on the %s rule", fe.Field(), fe.Tag())
+}
+
+// Converts go-playground validation errors into `ValidationError`. `root` is
+// the validated struct type.
+func newValidationError(root reflect.Type, errs validator.ValidationErrors) *ValidationError {
+	vE := &ValidationError{Fields: make([]FieldError, 0, len(errs))}
+
+	for _, fe := range errs {
+		// Without the top-level struct name, e.g.: `Server`.
+		field := fe.Namespace()
+
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		param := jsonParam(root, fe)
+
+		vE.Fields = append(vE.Fields, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   param,
+			Value:   fmt.Sprint(fe.Value()),
+			Message: message(fe, param),
+		})
+	}
+
+	return vE
+}
+
+// Get safely returns the application validator.
+func Get() *validator.Validate {
+	setupOnce.Do(setup)
+
+	return validatorSingleton
+}
+
+// RegisterValidation registers a custom validation `tag`, e.g.: `semver`,
+// within the shared validator, used by the server, and its configuration.
+// `message` is the human readable error, where `{0}` is the field, and `{1}`
+// the rule param, e.g.: "{0} must be a semantic version".
+//
+// NOTE: Registration isn't safe to run concurrently with validation. Register
+// before validating, e.g.: on `init`.
+func RegisterValidation(tag string, fn Func, message string) error {
+	Get()
+
+	return registerValidation(tag, fn, message)
+}
+
+// RegisterStructValidation registers struct-level rules for `types`, e.g.:
+// fields which depend on each other. Report errors via
+// `StructLevel.ReportError`, naming a tag with a message, see
+// `RegisterMessage`.
+//
+// NOTE: Registration isn't safe to run concurrently with validation. Register
+// before validating, e.g.: on `init`.
+func RegisterStructValidation(fn StructLevelFunc, types ...interface{}) {
+	Get().RegisterStructValidation(fn, types...)
+}
+
+// RegisterAlias registers `alias` for `tags`, e.g.: `RegisterAlias("port",
+// "numeric,gte=0,lte=65535", "{0} must be a port")`. `message` is the human
+// readable error, see `RegisterValidation`.
+//
+// NOTE: Registration isn't safe to run concurrently with validation. Register
+// before validating, e.g.: on `init`.
+func RegisterAlias(alias, tags, message string) error {
+	Get().RegisterAlias(alias, tags)
+
+	return registerMessage(alias, message)
+}
+
+// RegisterMessage registers, or overrides the human readable error for `tag`,
+// where `{0}` is the field, and `{1}` the rule param.
+//
+// NOTE: Registration isn't safe to run concurrently with validation. Register
+// before validating, e.g.: on `init`.
+func RegisterMessage(tag, message string) error {
+	Get()
+
+	return registerMessage(tag, message)
+}
+
+// ValidateStruct allows DRY around the repetitive work of validating structs.
+// Invalid fields are listed by the wrapped `ValidationError`, see `errors.As`.
+func ValidateStruct(f interface{}) error {
+	if err := Get().Struct(f); err != nil {
+		var errs validator.ValidationErrors
+
+		if errors.As(err, &errs) {
+			err = newValidationError(reflect.TypeOf(f), errs)
+		}
+
+		return customerror.NewInvalidError("data", customerror.WithError(err))
+	}
+
+	return nil
+}
@@ -6,9 +6,14 @@ package webserver
 
 import (
 	"context"
+	"errors"
+	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,9 +24,9 @@ import (
 	handler "github.com/saucelabs/webserver/handler"
 	"github.com/saucelabs/webserver/internal/logger"
 	"github.com/saucelabs/webserver/internal/middleware"
-	"github.com/saucelabs/webserver/internal/validation"
 	"github.com/saucelabs/webserver/metric"
 	"github.com/saucelabs/webserver/telemetry"
+	"github.com/saucelabs/webserver/validation"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
@@ -31,15 +36,24 @@ import (
 
 const (
 	defaultTimeout             = 3 * time.Second
+	defaultIdleTimeout         = 60 * time.Second
+	defaultMaxHeaderBytes      = 64 << 10
+	defaultReadHeaderTimeout   = 2 * time.Second
 	defaultRequestTimeout      = 1 * time.Second
 	defaultShutdownTaskTimeout = 10 * time.Second
 	frameworkName              = "webserver"
 )
 
-// ErrRequesTimeout indicates a request failed to finish, it timed out.
-var ErrRequesTimeout = customerror.NewFailedToError(
-	"finish request, timed out",
-	customerror.WithStatusCode(http.StatusRequestTimeout),
+var (
+	// ErrRequesTimeout indicates a request failed to finish, it timed out.
+	ErrRequesTimeout = customerror.NewFailedToError(
+		"finish request, timed out",
+		customerror.WithStatusCode(http.StatusRequestTimeout),
+	)
+
+	// ErrServerStarted indicates the server was already started, it runs only
+	// once.
+	ErrServerStarted = customerror.New("server was already started")
 )
 
 //////
@@ -55,10 +69,49 @@ type IServer interface {
 	GetRouter() *mux.Router
 	GetTelemetry() telemetry.ITelemetry
 
-	// Start the server.
+	// GetAdminRouter returns the router for built-in operational handlers.
+	GetAdminRouter() *mux.Router
+
+	// Addr returns the address the server is listening on, e.g.: the actual
+	// port when listening on ":0". Before that, it's the configured address.
+	Addr() string
+
+	// AdminAddr returns the address the admin listener is listening on.
+	AdminAddr() string
+
+	// Ready is closed once the server is accepting connections.
+	Ready() <-chan struct{}
+
+	// State returns the server lifecycle state.
+	State() State
+
+	// OnStateChange registers `fn` to be called on every server lifecycle
+	// state transition.
+	OnStateChange(fn StateChangeFunc)
+
+	// Start the server. It blocks until the server stops.
 	Start() error
 
+	// Run the server. It blocks until the server stops, or `ctx` is canceled.
+	// It returns `nil` once `ctx` is canceled, and `http.ErrServerClosed` once
+	// `Shutdown` is called.
+	Run(ctx context.Context) error
+
+	// Reload the server reloadable parts, e.g.: TLS certificates.
+	Reload() error
+
+	// Upgrade performs a zero-downtime binary upgrade.
+	Upgrade() error
+
+	// Shutdown gracefully shuts down the server.
+	Shutdown(ctx context.Context) error
+
+	// OnShutdown registers a task which runs once the server is shut down.
+	OnShutdown(name string, fn ShutdownTaskFunc)
+
 	// Stop the server.
+	//
+	// Deprecated: Use `Shutdown` instead.
 	Stop(sig os.Signal) error
 }
 
@@ -80,6 +133,15 @@ type Logging struct {
 
 // Timeout definition.
 type Timeout struct {
+	// IdleTimeout max duration to WAIT FOR THE NEXT REQUEST on keep-alive
+	// connections, default: 60s.
+	IdleTimeout time.Duration `json:"idle_timeout" validate:"gte=0"`
+
+	// ReadHeaderTimeout max duration for READING the request headers,
+	// default: 2s. It avoids "slowloris" attacks. Set to `0` to use
+	// `ReadTimeout`. It can't be larger than `ReadTimeout`.
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout" validate:"gte=0,ltefield=ReadTimeout"`
+
 	// ReadTimeout max duration for READING the entire request, including the
 	// body, default: 3s.
 	ReadTimeout time.Duration `json:"read_timeout"`
@@ -91,26 +153,67 @@ type Timeout struct {
 	// be smaller.
 	RequestTimeout time.Duration `json:"request_timeout" validate:"ltfield=ReadTimeout"`
 
+	// RequestTimeoutMode is how requests time out, either `TimeoutModeBuffer`,
+	// or `TimeoutModeContext` which doesn't buffer responses, default:
+	// "buffer". See `handler.Handler` for per-handler timeouts, and streaming.
+	RequestTimeoutMode string `json:"request_timeout_mode" validate:"required,oneof=buffer context"`
+
+	// ShutdownDrainDelay max duration to KEEP SERVING REQUESTS once shutdown
+	// starts, while reporting not-ready, so load balancers (e.g.: Kubernetes
+	// rolling updates) stop routing traffic before in-flight requests are
+	// waited, default: 0s.
+	ShutdownDrainDelay time.Duration `json:"shutdown_drain_delay" validate:"gte=0"`
+
 	// ShutdownInFlightTimeout max duration to WAIT IN-FLIGHT REQUESTS,
 	// default: 3s.
 	ShutdownInFlightTimeout time.Duration `json:"shutdown_in_flight_timeout"`
 
 	// ShutdownTaskTimeout max duration TO WAIT for tasks such as flush cache,
-	// files, and telemetry, default: 10s.
+	// files, and telemetry, default: 10s. Shutdown doesn't wait longer than
+	// the registered tasks take, see `OnShutdown`.
 	ShutdownTaskTimeout time.Duration `json:"shutdown_task_timeout"`
 
+	// UpgradeTimeout max duration to WAIT FOR THE NEW PROCESS to be ready
+	// during an upgrade, default: 10s. See `Upgrade`.
+	UpgradeTimeout time.Duration `json:"upgrade_timeout" validate:"gte=0"`
+
 	// ShutdownTimeout max duration for WRITING the response, default: 3s.
 	WriteTimeout time.Duration `json:"write_timeout"`
 }
 
+// Writes HTTP server errors, e.g.: TLS handshake failures, to the logger.
+type errorLogWriter struct {
+	l sypl.ISypl
+}
+
+// Write interface implementation.
+func (w *errorLogWriter) Write(p []byte) (int, error) {
+	w.l.Errorln(strings.TrimSuffix(string(p), "\n"))
+
+	return len(p), nil
+}
+
 // Server definition.
 type Server struct {
-	// Address is a TCP address to listen on.
-	Address string `json:"address" validate:"required,hostname_port"`
+	// Address to listen on. It's either:
+	// - a TCP address, e.g.: `host:port`. Port `0` picks an ephemeral port,
+	// see `Addr`
+	// - a Unix domain socket, e.g.: `unix:/path.sock`. See `SocketMode`
+	// - a systemd socket activation file descriptor, e.g.: `systemd:`, the
+	// first one, or `systemd:name`, see `LISTEN_FDNAMES`.
+	Address string `json:"address" validate:"required,listen_address"`
+
+	// Admin enables a dedicated listener for built-in operational handlers,
+	// default: none. See `WithAdminAddress`.
+	Admin *Admin `json:"admin" validate:"omitempty"`
 
 	// EnableMetrics controls whether metrics are enable, or not, default: false.
 	EnableMetrics bool `json:"enable_metrics"`
 
+	// EnableSystemdNotify controls whether systemd is notified about the
+	// server lifecycle (`Type=notify` services), or not, default: false.
+	EnableSystemdNotify bool `json:"enable_systemd_notify"`
+
 	// EnableTelemetry controls whether telemetry are enable, or not,
 	// default: false.
 	EnableTelemetry bool `json:"enable_telemetry"`
@@ -118,18 +221,83 @@ type Server struct {
 	// Name of the server.
 	Name string `json:"name" validate:"required,gte=3"`
 
+	// ParallelShutdownTasks controls whether shutdown tasks run in parallel,
+	// or in the order they were registered, default: false.
+	ParallelShutdownTasks bool `json:"parallel_shutdown_tasks"`
+
+	// SocketMode is the Unix domain socket permissions, default: 0660.
+	SocketMode os.FileMode `json:"socket_mode"`
+
+	// MaxHeaderBytes max size of the request headers, default: 64KiB.
+	MaxHeaderBytes int `json:"max_header_bytes" validate:"gt=0"`
+
 	// Logging fine-control.
 	*Logging `json:"logging" validate:"required"`
 
 	// Timeouts fine-control.
 	*Timeout `json:"timeout" validate:"required"`
 
+	// TLS enables HTTPS, and optionally mutual TLS, default: none.
+	TLS *TLS `json:"tls" validate:"omitempty"`
+
+	// Address the server is listening on.
+	addr net.Addr `json:"-"`
+
+	// Address the admin listener is listening on.
+	adminAddr net.Addr `json:"-"`
+
+	// Closed once the admin server is shut down, see `shutdownAdmin`.
+	adminDone chan struct{} `json:"-"`
+
+	// Listener the admin server is accepting connections from.
+	adminListener net.Listener `json:"-"`
+
+	// Router for built-in operational handlers, if `Admin` is set.
+	adminRouter *mux.Router `json:"-"`
+
+	// HTTP server for built-in operational handlers, if `Admin` is set.
+	adminServer *http.Server `json:"-"`
+
+	// Loads, and hot reloads TLS certificates.
+	certReloader *certReloader `json:"-"`
+
+	// Readiness checks run in the background while the server runs,
+	// default: none.
+	checks []*handler.CheckDeterminer `json:"-"`
+
+	// Reloads the configuration file, if the server was created from one.
+	// See `NewFromConfig`.
+	configReloader *configReloader `json:"-"`
+
+	// How often the configuration file is checked for changes, default: 0,
+	// disabled. See `WithConfigReloadInterval`.
+	configReloadInterval time.Duration `json:"-"`
+
+	// Returns the base context for incoming requests, default: none.
+	baseContext func(net.Listener) context.Context `json:"-"`
+
+	// Modifies the context for new connections, default: none.
+	connContext func(ctx context.Context, c net.Conn) context.Context `json:"-"`
+
+	// Logs errors accepting connections, and unexpected handler behavior,
+	// default: the server logger, at the error level.
+	errorLog *log.Logger `json:"-"`
+
 	// Handlers added, and configured before the server starts, default: none.
 	handlers []handler.Handler `json:"-"`
 
+	// Listener the server is accepting connections from.
+	listener net.Listener `json:"-"`
+
 	// Logger powered by Sypl.
 	logger *sypl.Sypl `json:"-" validate:"required"`
 
+	// Changes the logger levels, and file at runtime.
+	loggerController *logger.Controller `json:"-"`
+
+	// Guards the server runtime state, e.g.: `addr`, `listener`, and `state`.
+	m sync.Mutex `json:"-"`
+
 	// Metrics added, and configured before the server starts, default: none.
 	metrics []metric.Metric `json:"-"`
 
@@ -137,14 +305,83 @@ type Server struct {
 	// default: none.
 	readinessDeterminers []*handler.ReadinessDeterminer `json:"-"`
 
+	// Readiness states forced per determiner name, by the configuration,
+	// default: none. See `applyReadinessOverrides`.
+	readinessOverrides map[string]bool `json:"-"`
+
+	// Closed once the server is accepting connections.
+	ready chan struct{} `json:"-"`
+
+	// OS signals which reload the server, default: none.
+	reloadSignals []os.Signal `json:"-"`
+
+	// Per-route timeout settings, see `handler.Handler`.
+	routeTimeouts sync.Map `json:"-"`
+
+	// Current request timeout settings, see `requestTimeoutSettings`.
+	requestTimeout atomic.Value `json:"-"`
+
+	// Requests which timed out, per route.
+	requestTimeouts *metric.Map `json:"-"`
+
 	// Router powered by Gorilla Mux.
 	router *mux.Router `json:"-" validate:"required"`
 
+	// OS signals which gracefully shut down the server, default: none.
+	signals []os.Signal `json:"-"`
+
 	// HTTP server powered by Golang's built-in http server.
-	server http.Server `json:"-" validate:"required"`
+	server *http.Server `json:"-" validate:"required"`
+
+	// Closed once `Shutdown` finishes.
+	shutdownDone chan struct{} `json:"-"`
+
+	// Result of `Shutdown`.
+	shutdownErr error `json:"-"`
+
+	// Guarantees `Shutdown` runs only once.
+	shutdownOnce sync.Once `json:"-"`
+
+	// OS signals which upgrade the server, default: none.
+	upgradeSignals []os.Signal `json:"-"`
+
+	// Set once `Upgrade` completes, before shutting down the server.
+	upgraded bool `json:"-"`
+
+	// Tasks which run once the server is shut down, default: none.
+	shutdownTasks []ShutdownTask `json:"-"`
+
+	// Guards `shutdownTasks`.
+	shutdownTasksMutex sync.Mutex `json:"-"`
+
+	// Whether `Run` was called.
+	started bool `json:"-"`
+
+	// Lifecycle state.
+	state State `json:"-"`
+
+	// Built-in stop handler options, see `NewDefault`, default: none.
+	stopOptions []handler.StopOption `json:"-"`
+
+	// Startup determiners, see `WithStartup`.
+	startupDeterminers []*handler.ReadinessDeterminer `json:"-"`
+
+	// Called on every lifecycle state transition.
+	stateChangeFuncs []StateChangeFunc `json:"-"`
+
+	// Readiness determiner which is only ready while serving. It's always
+	// consulted by the built-in readiness handler.
+	stateDeterminer *handler.ReadinessDeterminer `json:"-"`
 
 	// Telemetry powered by OpenTelemetry, default: none.
 	telemetry telemetry.ITelemetry `json:"-"`
+
+	// Stops notifying the systemd watchdog.
+	watchdogCancel context.CancelFunc `json:"-"`
+
+	// Liveness determiners gating the systemd watchdog, see
+	// `WithSystemdWatchdog`.
+	watchdogDeterminers []*handler.ReadinessDeterminer `json:"-"`
 }
 
 //////
@@ -166,106 +403,492 @@ func (s *Server) GetTelemetry() telemetry.ITelemetry {
 	return s.telemetry
 }
 
-// Start the server.
+// Addr returns the address the server is listening on, e.g.: the actual port
+// when listening on ":0". Before that, it's the configured address.
+func (s *Server) Addr() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.addr == nil {
+		return s.Address
+	}
+
+	return formatAddr(s.addr)
+}
+
+// Ready is closed once the server is accepting connections.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Start the server. It blocks until the server stops. It's the same as calling
+// `Run` with a background context.
 func (s *Server) Start() error {
-	// Instantiates the underlying HTTP server.
-	s.server = http.Server{
-		Addr: s.Address,
-		Handler: http.TimeoutHandler(
-			s.GetRouter(),
-			s.Timeout.RequestTimeout,
-			ErrRequesTimeout.Error(),
-		),
+	return s.Run(context.Background())
+}
 
-		// Best practice setting timeouts. It avoid "slowloris" attacks.
-		ReadTimeout:  s.Timeout.ReadTimeout,
-		WriteTimeout: s.Timeout.WriteTimeout,
+// Run the server. It blocks until the server stops. Canceling `ctx`, or
+// receiving any of the signals set via `WithSignals` gracefully shuts down the
+// server, returning `nil`, or the shutdown error, as does a completed
+// `Upgrade`. If the server is shut down otherwise, e.g.: calling `Shutdown`, it
+// returns `http.ErrServerClosed`. A server runs only once, subsequent calls
+// return `ErrServerStarted`.
+func (s *Server) Run(ctx context.Context) error {
+	// A server can't be restarted once it was shut down.
+	select {
+	case <-s.shutdownDone:
+		return http.ErrServerClosed
+	default:
+	}
+
+	// Nor run twice.
+	s.m.Lock()
+	started := s.started
+	s.started = true
+	s.m.Unlock()
+
+	if started {
+		return ErrServerStarted
+	}
+
+	s.setState(StateStarting)
+
+	// Listening before serving allows errors (e.g.: "port in use") to be
+	// promptly reported, and ephemeral ports (e.g.: ":0") to be known.
+	listener, adminListener, inherited, err := s.listen()
+	if err != nil {
+		s.setState(StateStopped)
+
+		return err
 	}
 
 	serverErr := make(chan error, 1)
 
 	// Non-blocking server start up.
 	go func() {
-		s.GetLogger().Debuglnf("server is about to start @ %s", s.Address)
-		serverErr <- s.server.ListenAndServe()
+		serverErr <- s.serve(s.server, listener)
 	}()
 
-	// Listen for "catchable" OS signals, forget SIGKILL...
-	osSignals := make(chan os.Signal, 1)
-	signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM)
+	adminErr := make(chan error, 1)
+
+	if adminListener != nil {
+		go func() {
+			adminErr <- s.serve(s.adminServer, adminListener)
+		}()
+
+		s.GetLogger().Debuglnf("admin server is listening @ %s", formatAddr(adminListener.Addr()))
+	}
+
+	stopWatching := s.watch()
+	defer stopWatching()
+
+	s.GetLogger().Debuglnf("server is listening @ %s", formatAddr(listener.Addr()))
+
+	s.setState(StateServing)
+
+	close(s.ready)
+
+	// Upgraded from a parent process, which waits for this one to be ready.
+	if inherited {
+		if err := notifyUpgradeParent(); err != nil {
+			s.GetLogger().Errorln(err)
+		}
+	}
+
+	signals, stopSignals := s.notifySignals()
+	defer stopSignals()
 
 	// Block execution, and listen for any server errors (e.g.: "port in use"),
-	// or OS signals.
-	select {
-	// These errors don't require graceful shutdown.
-	case err := <-serverErr:
+	// context cancelation, or OS signals.
+	if shutdown, err := s.wait(ctx, signals, serverErr, adminErr, adminListener != nil); !shutdown {
 		return err
-	case sig := <-osSignals:
-		const crtlCmsg = "press ctrl+c to stop anyway"
+	}
 
-		s.logger.PrintNewLine()
-		s.GetLogger().Tracelnf("Got %s signal, gracefully shutting down", sig)
-		s.GetLogger().Tracelnf("Waiting %s for inflight requests to finish, %s", s.ShutdownInFlightTimeout, crtlCmsg)
+	shutdownErr := s.Shutdown(context.Background())
 
-		// Let Go terminate the program if we get that signal again.
-		signal.Reset(sig)
+	// The admin server stops once the server is shut down.
+	if adminListener != nil {
+		<-s.adminDone
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownInFlightTimeout)
-		defer cancel()
+	if shutdownErr != nil {
+		return shutdownErr
+	}
+
+	// If reaches here, error can be safely collected. Shut down by `ctx`, or a
+	// signal, it's a clean stop.
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Returns a HTTP server for `handler`, tuned according to the server settings.
+func (s *Server) newHTTPServer(addr string, h http.Handler, readTimeout, writeTimeout time.Duration) *http.Server {
+	return &http.Server{
+		Addr:    addr,
+		Handler: h,
+
+		// Best practice setting timeouts. It avoid "slowloris" attacks.
+		ReadHeaderTimeout: s.Timeout.ReadHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       s.Timeout.IdleTimeout,
+
+		MaxHeaderBytes: s.MaxHeaderBytes,
+		ErrorLog:       s.errorLog,
+		BaseContext:    s.baseContext,
+		ConnContext:    s.connContext,
+	}
+}
 
-		var shutdownErr error
-
-		s.server.SetKeepAlivesEnabled(false)
-
-		// Attempt to gracefully shutdown by closing the listener, waiting the
-		// completion of all inflight requests.
-		if err := s.server.Shutdown(ctx); err != nil {
-			if isTimeoutError(shutdownErr) {
-				shutdownErr = customerror.NewFailedToError(
-					"gracefully shutdown, timeout reached. Stopping hard...",
-					customerror.WithError(err),
-				)
-			} else {
-				shutdownErr = err
-			}
-
-			// Well.. KIH: Kill It Hard.
-			if err := s.server.Close(); err != nil {
-				shutdownErr = customerror.NewFailedToError(
-					"hardly shutdown the server",
-					customerror.WithError(err),
-				)
-			}
+// Serves `server` from `listener`, with TLS if enabled.
+func (s *Server) serve(server *http.Server, listener net.Listener) error {
+	if s.certReloader != nil {
+		// Certificates are provided by `TLSConfig`.
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
+}
+
+// Reload the server reloadable parts, e.g.: the configuration file, see
+// `NewFromConfig`, and TLS certificates. If it fails, the current state is
+// kept.
+func (s *Server) Reload() error {
+	if s.configReloader != nil {
+		if err := s.reloadConfig(); err != nil {
+			return err
 		}
+	}
 
-		if shutdownErr != nil {
-			return shutdownErr
+	if s.certReloader != nil {
+		if err := s.certReloader.reload(); err != nil {
+			return err
 		}
 
-		// Wait for tasks such as flush cache and files, and telemetry.
-		s.GetLogger().Tracelnf("Waiting %s for tasks, %s", s.ShutdownTaskTimeout, crtlCmsg)
+		s.GetLogger().Debuglnf("TLS certificates reloaded")
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down the server: disables keep-alives, and waits
+// in-flight requests to finish. If that doesn't happen in time - whichever
+// comes first, `ctx` or `ShutdownInFlightTimeout` - the server is hard stopped.
+// Then shutdown tasks run, under `ShutdownTaskTimeout`, or `ctx`, whichever
+// comes first. The admin listener, if any, keeps serving meanwhile, and shuts
+// down once `Shutdown` returns, waiting for in-flight requests, e.g.: a stop
+// request - `Run` returns once it did.
+// Only this server is affected. It's safe to be called multiple times, and
+// concurrently, subsequent calls return the result of the first one.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.setState(StateDraining)
 
-		time.Sleep(s.ShutdownTaskTimeout)
+		s.shutdownErr = s.shutdown(ctx)
 
-		// If reaches here, error can be safely collected.
-		return <-serverErr
+		s.setState(StateStopped)
+
+		close(s.shutdownDone)
+	})
+
+	return s.shutdownErr
+}
+
+// Sets up telemetry, defaulting to the `stdout` provider, and flushes it on
+// shutdown, if the provider supports it.
+func (s *Server) setupTelemetry() error {
+	if s.GetTelemetry() == nil {
+		defaultTelemetry, err := telemetry.StdoutProvider(s.Name)
+		if err != nil {
+			return err
+		}
+
+		s.telemetry = defaultTelemetry
+	}
+
+	if t, ok := s.GetTelemetry().(*telemetry.Telemetry); ok {
+		if provider, ok := t.Provider.(interface {
+			Shutdown(ctx context.Context) error
+		}); ok {
+			s.OnShutdown("telemetry", provider.Shutdown)
+		}
 	}
+
+	s.GetRouter().Use(otelmux.Middleware(s.Name))
+
+	return nil
 }
 
-// Stop the server.
-func (s *Server) Stop(sig os.Signal) error {
-	p, err := os.FindProcess(os.Getpid())
+// Loads TLS certificates, shared by the server, and the admin server.
+func (s *Server) setupTLS() error {
+	certReloader, err := newCertReloader(s.TLS)
 	if err != nil {
 		return err
 	}
 
-	return p.Signal(sig)
+	s.certReloader = certReloader
+
+	s.server.TLSConfig = certReloader.tlsConfig()
+
+	if s.adminServer != nil {
+		s.adminServer.TLSConfig = certReloader.tlsConfig()
+	}
+
+	return nil
+}
+
+// Adds handlers, and wires readiness: overrides, checks, and probes.
+func (s *Server) setupHandlers() error {
+	s.addHandler(s.GetRouter(), s.handlers...)
+
+	if err := s.applyReadinessOverrides(s.readinessOverrides); err != nil {
+		return err
+	}
+
+	if len(s.checks) > 0 {
+		s.setupChecks()
+	}
+
+	// The server itself is only ready while serving, e.g.: not ready once
+	// draining starts.
+	readinessStates := append([]*handler.ReadinessDeterminer{s.stateDeterminer}, s.readinessDeterminers...)
+
+	if s.readinessDeterminers != nil && len(s.readinessDeterminers) > 0 {
+		s.addHandler(s.GetAdminRouter(), handler.Readiness(readinessStates...))
+	}
+
+	s.addProbes(len(s.readinessDeterminers) > 0, len(s.startupDeterminers) > 0)
+
+	return nil
+}
+
+// Publishes metrics, and serves them.
+func (s *Server) publishMetrics() error {
+	for _, m := range s.metrics {
+		m := m
+
+		metric.Publish(m.Name, m.Value)
+	}
+
+	// Readiness checks runs, failures, and latency, per check.
+	if len(s.checks) > 0 {
+		if err := s.publishCheckMetrics(); err != nil {
+			return err
+		}
+	}
+
+	// Requests which timed out, per route. Servers with the same name share
+	// them.
+	requestTimeouts, err := publishedMap(s.Name+"_request_timeouts", s.requestTimeouts)
+	if err != nil {
+		return err
+	}
+
+	s.requestTimeouts = requestTimeouts
+
+	// Gorilla Mux exp var route registration.
+	s.addHandler(s.GetAdminRouter(), handler.Metrics())
+
+	return nil
+}
+
+// Adds the Kubernetes-style readiness (`/readyz`, and `/readyz/{name}`), and
+// startup (`/startupz`) probes. The server itself is only ready while serving,
+// and startup completes once it serves, and startup determiners are ready.
+func (s *Server) addProbes(readiness, startup bool) {
+	if readiness {
+		readinessStates := append([]*handler.ReadinessDeterminer{s.stateDeterminer}, s.readinessDeterminers...)
+
+		s.addHandler(s.GetAdminRouter(), handler.Readyz(readinessStates...), handler.ReadyzCheck(readinessStates...))
+	}
+
+	if startup {
+		startupStates := append([]*handler.ReadinessDeterminer{s.stateDeterminer}, s.startupDeterminers...)
+
+		s.addHandler(s.GetAdminRouter(), handler.Startupz(startupStates...))
+	}
+}
+
+// Logs stop requests, see `handler.WithStopAudit`.
+func (s *Server) auditStop(e handler.StopEvent) {
+	switch e.Stage {
+	case handler.StopDenied:
+		s.GetLogger().Warnlnf("Stop denied, remote address %s, request id %s. %s", e.RemoteAddr, e.RequestID, e.Err)
+	case handler.StopAccepted:
+		s.GetLogger().Warnlnf(
+			"Stop requested by %s, remote address %s, request id %s, timeout %s",
+			e.Who, e.RemoteAddr, e.RequestID, e.Timeout,
+		)
+	case handler.StopFinished:
+		if e.Err != nil {
+			s.GetLogger().Errorlnf("Stop requested by %s finished, request id %s. %s", e.Who, e.RequestID, e.Err)
+
+			return
+		}
+
+		s.GetLogger().Infolnf("Stop requested by %s finished, request id %s", e.Who, e.RequestID)
+	}
+}
+
+// Shutdown implementation, see `Shutdown`.
+func (s *Server) shutdown(ctx context.Context) error {
+	const crtlCmsg = "press ctrl+c to stop anyway"
+
+	// Load balancers (e.g.: kube-proxy) take a while to notice the server
+	// isn't ready anymore. Keep serving new requests meanwhile.
+	if s.ShutdownDrainDelay > 0 {
+		s.GetLogger().Tracelnf("Waiting %s before shutting down, still serving requests, %s", s.ShutdownDrainDelay, crtlCmsg)
+
+		select {
+		case <-time.After(s.ShutdownDrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	s.GetLogger().Tracelnf("Waiting %s for inflight requests to finish, %s", s.ShutdownInFlightTimeout, crtlCmsg)
+
+	inFlightCtx, cancel := context.WithTimeout(ctx, s.ShutdownInFlightTimeout)
+	defer cancel()
+
+	var shutdownErr error
+
+	s.server.SetKeepAlivesEnabled(false)
+
+	// Attempt to gracefully shutdown by closing the listener, waiting the
+	// completion of all inflight requests.
+	if err := s.server.Shutdown(inFlightCtx); err != nil {
+		if isTimeoutError(err) {
+			shutdownErr = customerror.NewFailedToError(
+				"gracefully shutdown, timeout reached. Stopping hard...",
+				customerror.WithError(err),
+			)
+		} else {
+			shutdownErr = err
+		}
+
+		// Well.. KIH: Kill It Hard.
+		if err := s.server.Close(); err != nil {
+			shutdownErr = customerror.NewFailedToError(
+				"hardly shutdown the server",
+				customerror.WithError(err),
+			)
+		}
+	}
+
+	// Run tasks such as flush cache and files, and telemetry - even if
+	// in-flight requests didn't finish in time, but not beyond `ctx`, e.g.: a
+	// group shutdown budget.
+	s.GetLogger().Tracelnf("Waiting up to %s for tasks, %s", s.ShutdownTaskTimeout, crtlCmsg)
+
+	tasksCtx, tasksCancel := context.WithTimeout(ctx, s.ShutdownTaskTimeout)
+	defer tasksCancel()
+
+	s.shutdownTasksMutex.Lock()
+	tasks := append([]ShutdownTask{}, s.shutdownTasks...)
+	s.shutdownTasksMutex.Unlock()
+
+	if err := runShutdownTasks(tasksCtx, s.ParallelShutdownTasks, tasks...); err != nil {
+		s.GetLogger().Errorln(err)
+
+		if shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	// The admin server keeps serving, e.g.: readiness, until the server is shut
+	// down, then replies in-flight requests, e.g.: a stop request waiting for
+	// the result, see `handler.WithStopWait`.
+	if s.adminServer != nil {
+		go s.shutdownAdmin(ctx)
+	}
+
+	return shutdownErr
+}
+
+// Gracefully shuts down the admin server, under `ShutdownInFlightTimeout`, or
+// stops it hard if `ctx` is already done. See `shutdown`.
+func (s *Server) shutdownAdmin(ctx context.Context) {
+	defer close(s.adminDone)
+
+	s.adminServer.SetKeepAlivesEnabled(false)
+
+	if ctx.Err() != nil {
+		_ = s.adminServer.Close()
+
+		return
+	}
+
+	// NOTE: `ctx` may be done once `Shutdown` returns, e.g.: a stop request.
+	adminCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownInFlightTimeout)
+	defer cancel()
+
+	if err := s.adminServer.Shutdown(adminCtx); err != nil {
+		s.GetLogger().Errorlnf("Failed to gracefully shutdown the admin server, stopping hard. %s", err)
+
+		_ = s.adminServer.Close()
+	}
+}
+
+// OnShutdown registers a task which runs once the server is shut down, e.g.:
+// flush telemetry, close DB pools, or flush log files. Tasks run in the order
+// they were registered, unless `ParallelShutdownTasks` is set, under the
+// `ShutdownTaskTimeout` budget.
+func (s *Server) OnShutdown(name string, fn ShutdownTaskFunc) {
+	s.shutdownTasksMutex.Lock()
+	defer s.shutdownTasksMutex.Unlock()
+
+	s.shutdownTasks = append(s.shutdownTasks, ShutdownTask{Name: name, Func: fn})
+}
+
+// Stop the server. `os.Kill` stops it hard, without waiting in-flight
+// requests, nor shutdown tasks, any other signal gracefully.
+//
+// Deprecated: Use `Shutdown` instead.
+func (s *Server) Stop(sig os.Signal) error {
+	ctx := context.Background()
+
+	if sig == os.Kill {
+		// No time for in-flight requests.
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, 0)
+		defer cancel()
+	}
+
+	return s.Shutdown(ctx)
 }
 
 //////
 // Factory.
 //////
 
+// Returns logging settings with defaults.
+func newLogging() *Logging {
+	return &Logging{
+		ConsoleLevel: level.None.String(),
+		RequestLevel: level.None.String(),
+		Filepath:     "",
+	}
+}
+
+// Returns timeout settings with defaults.
+func newTimeout() *Timeout {
+	return &Timeout{
+		IdleTimeout:             defaultIdleTimeout,
+		ReadHeaderTimeout:       defaultReadHeaderTimeout,
+		ReadTimeout:             defaultTimeout,
+		RequestTimeout:          defaultRequestTimeout,
+		RequestTimeoutMode:      TimeoutModeBuffer,
+		ShutdownInFlightTimeout: defaultTimeout,
+		ShutdownTaskTimeout:     defaultShutdownTaskTimeout,
+		UpgradeTimeout:          defaultUpgradeTimeout,
+		WriteTimeout:            defaultTimeout,
+	}
+}
+
 // New returns a basic web server without:
 // - logging
 // - telemetry
@@ -276,23 +899,20 @@ func New(name, address string, opts ...Option) (IServer, error) {
 		Address:         address,
 		EnableMetrics:   false,
 		EnableTelemetry: false,
+		MaxHeaderBytes:  defaultMaxHeaderBytes,
 		Name:            name,
-		Logging: &Logging{
-			ConsoleLevel: level.None.String(),
-			RequestLevel: level.None.String(),
-			Filepath:     "",
-		},
-		Timeout: &Timeout{
-			ReadTimeout:             defaultTimeout,
-			RequestTimeout:          defaultRequestTimeout,
-			ShutdownInFlightTimeout: defaultTimeout,
-			ShutdownTaskTimeout:     defaultShutdownTaskTimeout,
-			WriteTimeout:            defaultTimeout,
-		},
-
-		handlers: []handler.Handler{},
-		metrics:  []metric.Metric{},
-		router:   mux.NewRouter(),
+		SocketMode:      defaultSocketMode,
+		Logging:         newLogging(),
+		Timeout:         newTimeout(),
+
+		adminDone:       make(chan struct{}),
+		handlers:        []handler.Handler{},
+		metrics:         []metric.Metric{},
+		ready:           make(chan struct{}),
+		requestTimeouts: new(metric.Map).Init(),
+		router:          mux.NewRouter(),
+		shutdownDone:    make(chan struct{}),
+		state:           StateNew,
 	}
 
 	//////
@@ -303,36 +923,59 @@ func New(name, address string, opts ...Option) (IServer, error) {
 		opt(s)
 	}
 
+	s.stateDeterminer = handler.NewReadinessDeterminer(s.Name)
+
+	// Checks are readiness determiners too.
+	for _, check := range s.checks {
+		s.readinessDeterminers = append(s.readinessDeterminers, check.ReadinessDeterminer)
+	}
+
 	//////
 	// Logging.
 	//////
 
-	s.logger = logger.Setup(
+	l, loggerController := logger.Setup(
 		frameworkName,
 		s.Logging.ConsoleLevel,
 		s.Logging.RequestLevel,
 		s.Logging.Filepath,
-	).New(name)
+	)
+
+	s.logger = l.New(name)
+	s.loggerController = loggerController
 
-	s.GetRouter().Use(middleware.Logger(s.logger))
+	s.GetRouter().Use(middleware.Logger(s.loggerController.RequestWriter(s.logger)))
 
 	//////
 	// Telemetry.
 	//////
 
 	if s.EnableTelemetry {
-		if s.GetTelemetry() == nil {
-			defaultTelemetry, err := telemetry.StdoutProvider(name)
-			if err != nil {
-				return nil, err
-			}
-
-			s.telemetry = defaultTelemetry
+		if err := s.setupTelemetry(); err != nil {
+			return nil, err
 		}
+	}
 
-		s.GetRouter().Use(otelmux.Middleware(name))
+	//////
+	// HTTP server.
+	//////
+
+	if s.errorLog == nil {
+		s.errorLog = log.New(&errorLogWriter{l: s.logger}, "", 0)
 	}
 
+	s.requestTimeout.Store(requestTimeoutSettings{
+		mode:    s.Timeout.RequestTimeoutMode,
+		timeout: s.Timeout.RequestTimeout,
+	})
+
+	s.server = s.newHTTPServer(
+		s.Address,
+		s.timeoutHandler(s.GetRouter(), func() time.Duration { return s.requestTimeoutSettings().timeout }),
+		s.Timeout.ReadTimeout,
+		s.Timeout.WriteTimeout,
+	)
+
 	//////
 	// Validation.
 	//////
@@ -342,13 +985,37 @@ func New(name, address string, opts ...Option) (IServer, error) {
 	}
 
 	//////
-	// Handlers.
+	// Admin.
 	//////
 
-	addHandler(s.GetRouter(), s.handlers...)
+	if s.Admin != nil {
+		s.setupAdmin()
+	}
 
-	if s.readinessDeterminers != nil && len(s.readinessDeterminers) > 0 {
-		addHandler(s.GetRouter(), handler.Readiness(s.readinessDeterminers...))
+	//////
+	// systemd.
+	//////
+
+	if s.EnableSystemdNotify {
+		s.OnStateChange(s.systemdStateChange)
+	}
+
+	//////
+	// TLS.
+	//////
+
+	if s.TLS != nil {
+		if err := s.setupTLS(); err != nil {
+			return nil, err
+		}
+	}
+
+	//////
+	// Handlers.
+	//////
+
+	if err := s.setupHandlers(); err != nil {
+		return nil, err
 	}
 
 	//////
@@ -356,14 +1023,9 @@ func New(name, address string, opts ...Option) (IServer, error) {
 	//////
 
 	if s.EnableMetrics {
-		for _, m := range s.metrics {
-			m := m
-
-			metric.Publish(m.Name, m.Value)
+		if err := s.publishMetrics(); err != nil {
+			return nil, err
 		}
-
-		// Gorilla Mux exp var route registration.
-		addHandler(s.GetRouter(), handler.Metrics())
 	}
 
 	return s, nil
@@ -373,9 +1035,15 @@ func New(name, address string, opts ...Option) (IServer, error) {
 // - Metrics: `cmdline`, `memstats`, and `server`
 // - Telemetry: `stdout` provider
 // - Logging: `error`, no file
-// - Pre-loaded handlers (Liveness, OK, and Stop)
+// - Pre-loaded handlers (Liveness, Livez, Readyz, Startupz, OK, and Stop -
+// localhost only, see `WithStopOptions`. Without an admin listener, Stop is
+// only served if requests are authenticated, e.g.: `handler.WithStopToken`)
+// - Signals: `os.Interrupt`, and `syscall.SIGTERM` gracefully shut it down
 // - Versioned router: `/api/v1`.
-func NewDefault(name, address string) (IServer, error) {
+//
+// `opts` override the defaults, e.g.: `WithAdminAddress` moves Liveness,
+// metrics, and Stop to the admin listener.
+func NewDefault(name, address string, opts ...Option) (IServer, error) {
 	defaulTelemetry, err := telemetry.StdoutProvider(name)
 	if err != nil {
 		return nil, err
@@ -385,10 +1053,8 @@ func NewDefault(name, address string) (IServer, error) {
 	apiRouter := defaultRouter.PathPrefix("/api").Subrouter()
 	versionedRouter := apiRouter.PathPrefix("/v1").Subrouter()
 
-	return New(
-		name,
-		address,
-		WithHandlers(handler.Liveness(), handler.OK(), handler.Stop()),
+	defaultOpts := []Option{
+		WithHandlers(handler.OK()),
 		WithMetrics(
 			metric.Metric{Name: "cmdline", Value: metric.CommandLine()},
 			metric.Metric{Name: "memstats", Value: metric.MemoryStats()},
@@ -396,6 +1062,36 @@ func NewDefault(name, address string) (IServer, error) {
 		),
 		WithLogging(level.Error.String(), level.Error.String(), ""),
 		WithRouter(versionedRouter),
+		WithSignals(os.Interrupt, syscall.SIGTERM),
 		WithTelemetry(defaulTelemetry),
-	)
+	}
+
+	s, err := New(name, address, append(defaultOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := s.(*Server)
+
+	srv.addHandler(s.GetAdminRouter(), handler.Liveness(), handler.Livez())
+
+	// Kubernetes-style probes, unless already added by `New`.
+	srv.addProbes(len(srv.readinessDeterminers) == 0, len(srv.startupDeterminers) == 0)
+
+	// Stop needs the server to be stopped. Stop requests are audited.
+	stopOpts := []handler.StopOption{handler.WithStopAudit(srv.auditStop)}
+
+	switch {
+	case srv.Admin != nil:
+		// Served by the admin server, it can wait for the server to shut down.
+		stopOpts = append(stopOpts, handler.WithStopWait())
+	case !handler.StopAuthenticated(srv.stopOptions...):
+		// Served publicly, e.g.: behind a reverse proxy, where requests come
+		// from localhost. Only if authenticated.
+		return s, nil
+	}
+
+	srv.addHandler(s.GetAdminRouter(), handler.Stop(s, append(stopOpts, srv.stopOptions...)...))
+
+	return s, nil
 }
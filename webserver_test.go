@@ -5,18 +5,24 @@
 package webserver
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/saucelabs/randomness"
 	"github.com/saucelabs/webserver/handler"
 	"github.com/saucelabs/webserver/metric"
 )
@@ -26,25 +32,24 @@ const serverName = "test-server"
 // Client simulation.
 var c = http.Client{Timeout: time.Duration(10) * time.Second}
 
-// Generates random ports.
-func generatePort(t *testing.T) int64 {
+// Waits for the server to be ready, and returns its address.
+func waitReady(t *testing.T, testServer IServer) string {
 	t.Helper()
 
-	// Random port.
-	r, err := randomness.New(3000, 7000, 10, true)
-	if err != nil {
-		t.Fatal(err)
+	select {
+	case <-testServer.Ready():
+		return testServer.Addr()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected server to be ready")
 	}
 
-	return r.MustGenerate()
+	return ""
 }
 
 // Setup a test server.
-func setupTestServer(t *testing.T) (IServer, int) {
+func setupTestServer(t *testing.T) IServer {
 	t.Helper()
 
-	port := generatePort(t)
-
 	// A classic metric counter.
 	counterMetric := metric.NewInt("simple_metric_example_counter")
 	counterMetric.Add(1)
@@ -55,13 +60,12 @@ func setupTestServer(t *testing.T) (IServer, int) {
 	versionedRouter := apiRouter.PathPrefix("/v1").Subrouter()
 
 	// Test server setting many options...
-	testServer, err := New(serverName, fmt.Sprintf("0.0.0.0:%d", port),
+	testServer, err := New(serverName, "127.0.0.1:0",
 		WithRouter(versionedRouter),
 		// Add a custom handler to the list of pre-loaded handlers.
 		WithHandlers(
 			handler.Liveness(),
 			handler.OK(),
-			handler.Stop(),
 			// Simulates a slow operation which should timeout.
 			handler.Handler{
 				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -100,12 +104,17 @@ func setupTestServer(t *testing.T) (IServer, int) {
 				}
 			}),
 		}),
+		WithSignals(os.Interrupt, syscall.SIGTERM),
 		WithTimeout(3*time.Second, 1*time.Second, 3*time.Second, 10*time.Second, 3*time.Second),
 	)
 	if err != nil {
 		log.Fatalf("Failed to setup %s, %v", serverName, err)
 	}
 
+	// Stop needs the server to be stopped.
+	stopHandler := handler.Stop(testServer)
+	testServer.GetRouter().HandleFunc(stopHandler.Path, stopHandler.Handler).Methods(stopHandler.Method)
+
 	// This is how a developer, importing this package would add routers, and
 	// routes.
 	sr := testServer.GetRouter().PathPrefix("/router2").Subrouter()
@@ -119,13 +128,28 @@ func setupTestServer(t *testing.T) (IServer, int) {
 		counterMetric.Add(1)
 	})
 
-	return testServer, int(port)
+	return testServer
 }
 
-func callAndExpect(t *testing.T, port int, url string, sc int, expectedBodyContains string) {
+func callAndExpect(t *testing.T, addr string, url string, sc int, expectedBodyContains string) {
 	t.Helper()
 
-	resp, err := c.Get(fmt.Sprintf("http://0.0.0.0:%d/%s", port, url))
+	requestAndExpect(t, http.MethodGet, addr, url, nil, sc, expectedBodyContains)
+}
+
+func requestAndExpect(t *testing.T, method, addr, url string, header http.Header, sc int, expectedBodyContains string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/%s", addr, url), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -158,7 +182,7 @@ func callAndExpect(t *testing.T, port int, url string, sc int, expectedBodyConta
 
 func TestNew(t *testing.T) {
 	// Test server.
-	testServer, port := setupTestServer(t)
+	testServer := setupTestServer(t)
 
 	// Starts in a non-blocking way.
 	go func() {
@@ -171,11 +195,11 @@ func TestNew(t *testing.T) {
 		}
 	}()
 
-	// Ensures enough time for the server to be up, and ready - just for testing.
-	time.Sleep(3 * time.Second)
+	addr := waitReady(t, testServer)
 
 	type args struct {
-		port                 int
+		addr                 string
+		method               string
 		url                  string
 		sc                   int
 		expectedBodyContains string
@@ -188,7 +212,7 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - liveness",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/liveness",
 				sc:                   http.StatusOK,
 				expectedBodyContains: http.StatusText(http.StatusOK),
@@ -197,7 +221,7 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - /",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/",
 				sc:                   http.StatusOK,
 				expectedBodyContains: http.StatusText(http.StatusOK),
@@ -206,7 +230,7 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - /ok",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/ok",
 				sc:                   http.StatusOK,
 				expectedBodyContains: http.StatusText(http.StatusOK),
@@ -215,7 +239,7 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - sub-router - /router2/counter",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/router2/counter",
 				sc:                   http.StatusOK,
 				expectedBodyContains: http.StatusText(http.StatusOK),
@@ -224,7 +248,7 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - /debug/vars - counter",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/debug/vars",
 				sc:                   http.StatusOK,
 				expectedBodyContains: `"simple_metric_example_counter": 2`,
@@ -233,27 +257,37 @@ func TestNew(t *testing.T) {
 		{
 			name: "Should work - /slow",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/api/v1/slow",
-				sc:                   http.StatusServiceUnavailable,
+				sc:                   http.StatusRequestTimeout,
 				expectedBodyContains: ErrRequesTimeout.Error(),
 				delay:                3 * time.Second,
 			},
 		},
+		{
+			name: "Should fail - /stop, GET",
+			args: args{
+				addr:   addr,
+				method: http.MethodGet,
+				url:    "/api/v1/stop",
+				sc:     http.StatusMethodNotAllowed,
+			},
+		},
 		{
 			name: "Should work - /stop",
 			args: args{
-				port:                 port,
-				url:                  "/api/v1/stop",
-				sc:                   http.StatusOK,
-				expectedBodyContains: http.StatusText(http.StatusOK),
+				addr:                 addr,
+				method:               http.MethodPost,
+				url:                  "api/v1/stop",
+				sc:                   http.StatusAccepted,
+				expectedBodyContains: "shutting down",
 				delay:                3 * time.Second,
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			callAndExpect(t, tt.args.port, tt.args.url, tt.args.sc, tt.args.expectedBodyContains)
+			requestAndExpect(t, tt.args.method, tt.args.addr, tt.args.url, nil, tt.args.sc, tt.args.expectedBodyContains)
 		})
 	}
 }
@@ -263,10 +297,10 @@ func TestNew_address(t *testing.T) {
 		host string
 	}
 	tests := []struct {
-		name           string
-		args           args
-		needRandomPort bool
-		wantErr        bool
+		name     string
+		args     args
+		needPort bool
+		wantErr  bool
 	}{
 		{
 			name: "Should fail - empty",
@@ -283,28 +317,28 @@ func TestNew_address(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "Should work - :NNNN -> localhost:NNNN",
+			name: "Should work - :0 -> localhost:ephemeral",
 			args: args{
 				host: "",
 			},
-			needRandomPort: true,
-			wantErr:        false,
+			needPort: true,
+			wantErr:  false,
 		},
 		{
-			name: "Should work - localhost:NNNN",
+			name: "Should work - localhost:0",
 			args: args{
 				host: "localhost",
 			},
-			needRandomPort: true,
-			wantErr:        false,
+			needPort: true,
+			wantErr:  false,
 		},
 		{
-			name: "Should work - 0.0.0.0:NNNN",
+			name: "Should work - 0.0.0.0:0",
 			args: args{
 				host: "0.0.0.0",
 			},
-			needRandomPort: true,
-			wantErr:        false,
+			needPort: true,
+			wantErr:  false,
 		},
 	}
 	for _, tt := range tests {
@@ -312,8 +346,8 @@ func TestNew_address(t *testing.T) {
 			// Host, and port builder.
 			port := ""
 
-			if tt.needRandomPort {
-				port = fmt.Sprintf("%d", generatePort(t))
+			if tt.needPort {
+				port = "0"
 			}
 
 			address := fmt.Sprintf("%s:%s", tt.args.host, port)
@@ -354,15 +388,7 @@ func TestNew_address(t *testing.T) {
 }
 
 func TestNewBasic(t *testing.T) {
-	// Random port.
-	r, err := randomness.New(3000, 7000, 10, true)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	port := r.MustGenerate()
-
-	testServer, err := New(serverName, fmt.Sprintf("0.0.0.0:%d", port),
+	testServer, err := New(serverName, "127.0.0.1:0",
 		WithHandlers(
 			handler.Liveness(),
 		),
@@ -387,11 +413,14 @@ func TestNewBasic(t *testing.T) {
 		}
 	}()
 
-	// Ensures enough time for the server to be up, and ready - just for testing.
-	time.Sleep(3 * time.Second)
+	addr := waitReady(t, testServer)
+
+	if strings.HasSuffix(addr, ":0") {
+		t.Fatalf("Expected ephemeral port to be resolved, got %s", addr)
+	}
 
 	type args struct {
-		port                 int64
+		addr                 string
 		url                  string
 		sc                   int
 		expectedBodyContains string
@@ -405,16 +434,688 @@ func TestNewBasic(t *testing.T) {
 		{
 			name: "Should work - liveness",
 			args: args{
-				port:                 port,
+				addr:                 addr,
 				url:                  "/liveness",
 				sc:                   http.StatusOK,
 				expectedBodyContains: http.StatusText(http.StatusOK),
 			},
 		},
+		{
+			name: "Should work - no readiness probe without determiners",
+			args: args{
+				addr: addr,
+				url:  "/readyz",
+				sc:   http.StatusNotFound,
+			},
+		},
+		{
+			name: "Should work - no startup probe without determiners",
+			args: args{
+				addr: addr,
+				url:  "/startupz",
+				sc:   http.StatusNotFound,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			callAndExpect(t, int(tt.args.port), tt.args.url, tt.args.sc, tt.args.expectedBodyContains)
+			callAndExpect(t, tt.args.addr, tt.args.url, tt.args.sc, tt.args.expectedBodyContains)
 		})
 	}
 }
+
+func TestNewDefault(t *testing.T) {
+	// Metrics are published once per process.
+	withoutMetrics := func(s *Server) {
+		s.EnableMetrics = false
+	}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		admin  bool
+		url    string
+		sc     int
+		stops  bool
+		header http.Header
+	}{
+		{
+			name: "Should work - not served publicly, by default",
+			url:  "api/v1/stop",
+			sc:   http.StatusNotFound,
+		},
+		{
+			name:   "Should work - served publicly, if authenticated",
+			opts:   []Option{WithStopOptions(handler.WithStopToken("secret"))},
+			url:    "api/v1/stop",
+			sc:     http.StatusAccepted,
+			stops:  true,
+			header: http.Header{"Authorization": []string{"Bearer secret"}},
+		},
+		{
+			name:  "Should work - admin listener, waits for shutdown",
+			opts:  []Option{WithAdminAddress("127.0.0.1:0")},
+			admin: true,
+			url:   "stop",
+			sc:    http.StatusOK,
+			stops: true,
+		},
+		{
+			name: "Should work - admin listener, shutdown outlasts the request timeout",
+			opts: []Option{
+				WithAdminAddress("127.0.0.1:0"),
+				WithAdminTimeout(3*time.Second, 500*time.Millisecond, 1*time.Second),
+				WithShutdownDrainDelay(1500 * time.Millisecond),
+			},
+			admin: true,
+			url:   "stop",
+			sc:    http.StatusOK,
+			stops: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer, err := NewDefault(serverName, "127.0.0.1:0", append(tt.opts, withoutMetrics)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer testServer.Shutdown(context.Background())
+
+			runErr := make(chan error, 1)
+
+			go func() {
+				runErr <- testServer.Start()
+			}()
+
+			addr := waitReady(t, testServer)
+
+			if tt.admin {
+				addr = testServer.AdminAddr()
+			}
+
+			// Kubernetes-style probes are pre-loaded, next to stop.
+			prefix := strings.TrimSuffix(tt.url, "stop")
+
+			callAndExpect(t, addr, prefix+"readyz", http.StatusOK, "ok")
+			callAndExpect(t, addr, prefix+"startupz", http.StatusOK, "ok")
+
+			requestAndExpect(t, http.MethodPost, addr, tt.url, tt.header, tt.sc, "")
+
+			if !tt.stops {
+				return
+			}
+
+			select {
+			case err := <-runErr:
+				if !errors.Is(err, http.ErrServerClosed) {
+					t.Fatalf("Expected %v got %v", http.ErrServerClosed, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected server to stop")
+			}
+		})
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(handler.Liveness()),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 0, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runErr := make(chan error, 1)
+
+	go func() {
+		runErr <- testServer.Run(ctx)
+	}()
+
+	addr := waitReady(t, testServer)
+
+	callAndExpect(t, addr, "/liveness", http.StatusOK, http.StatusText(http.StatusOK))
+
+	// A server runs only once.
+	if err := testServer.Run(ctx); !errors.Is(err, ErrServerStarted) {
+		t.Fatalf("Expected %v got %v", ErrServerStarted, err)
+	}
+
+	// Canceling the context should gracefully shut down the server.
+	cancel()
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("Expected no error got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected server to stop once context is canceled")
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	newServer := func() IServer {
+		testServer, err := New(serverName, "127.0.0.1:0",
+			WithHandlers(handler.Liveness()),
+			WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return testServer
+	}
+
+	server1 := newServer()
+	server2 := newServer()
+
+	// Shutdown tasks run once the server is shut down.
+	taskDone := false
+
+	server1.OnShutdown("flag", func(ctx context.Context) error {
+		taskDone = true
+
+		return nil
+	})
+
+	runErr1 := make(chan error, 1)
+
+	go func() {
+		runErr1 <- server1.Start()
+	}()
+
+	go func() {
+		_ = server2.Start()
+	}()
+
+	defer server2.Shutdown(context.Background())
+
+	addr1 := waitReady(t, server1)
+	addr2 := waitReady(t, server2)
+
+	if err := server1.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !taskDone {
+		t.Fatal("Expected shutdown task to run")
+	}
+
+	select {
+	case err := <-runErr1:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Fatalf("Expected %v got %v", http.ErrServerClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected server to stop once shut down")
+	}
+
+	if _, err := c.Get(fmt.Sprintf("http://%s/liveness", addr1)); err == nil {
+		t.Fatal("Expected shut down server to refuse connections")
+	}
+
+	// Other servers are unaffected.
+	callAndExpect(t, addr2, "/liveness", http.StatusOK, http.StatusText(http.StatusOK))
+
+	// Subsequent calls return the result of the first one.
+	if err := server1.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_State(t *testing.T) {
+	readinessFlag := handler.NewReadinessDeterminer("flag")
+	readinessFlag.SetReadiness(true)
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithReadiness(readinessFlag),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if testServer.State() != StateNew {
+		t.Fatalf("Expected %s got %s", StateNew, testServer.State())
+	}
+
+	transitions := []string{}
+	transitionsMutex := sync.Mutex{}
+
+	testServer.OnStateChange(func(from, to State) {
+		transitionsMutex.Lock()
+		defer transitionsMutex.Unlock()
+
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	})
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	addr := waitReady(t, testServer)
+
+	if testServer.State() != StateServing {
+		t.Fatalf("Expected %s got %s", StateServing, testServer.State())
+	}
+
+	callAndExpect(t, addr, "/readiness", http.StatusOK, http.StatusText(http.StatusOK))
+
+	if err := testServer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if testServer.State() != StateStopped {
+		t.Fatalf("Expected %s got %s", StateStopped, testServer.State())
+	}
+
+	transitionsMutex.Lock()
+	defer transitionsMutex.Unlock()
+
+	expected := []string{"new->starting", "starting->serving", "serving->draining", "draining->stopped"}
+
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Fatalf("Expected %v got %v", expected, transitions)
+	}
+}
+
+func TestServer_Shutdown_drainDelay(t *testing.T) {
+	readinessFlag := handler.NewReadinessDeterminer("flag")
+	readinessFlag.SetReadiness(true)
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(handler.OK()),
+		WithReadiness(readinessFlag),
+		WithShutdownDrainDelay(2*time.Second),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	addr := waitReady(t, testServer)
+
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		shutdownErr <- testServer.Shutdown(context.Background())
+	}()
+
+	// Ensures enough time for the drain to start - just for testing.
+	time.Sleep(500 * time.Millisecond)
+
+	// Not ready, but still serving requests.
+	callAndExpect(t, addr, "/readiness", http.StatusServiceUnavailable, serverName)
+	callAndExpect(t, addr, "/readiness?verbose", http.StatusServiceUnavailable, serverName+": not ready, server is draining")
+	callAndExpect(t, addr, "/", http.StatusOK, http.StatusText(http.StatusOK))
+
+	if err := <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew_httpServerTuning(t *testing.T) {
+	type ctxKey struct{}
+
+	var errorLog strings.Builder
+
+	// Replies with the connection context value.
+	connContextHandler := handler.Handler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Context().Value(ctxKey{}))
+		}),
+		Method: http.MethodGet,
+		Path:   "/conn-context",
+	}
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(connContextHandler),
+		WithReadHeaderTimeout(500*time.Millisecond),
+		WithIdleTimeout(5*time.Second),
+		WithMaxHeaderBytes(1<<10),
+		WithErrorLog(log.New(&errorLog, "", 0)),
+		WithConnContext(func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, ctxKey{}, "from-conn")
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testServer.(*Server).server
+
+	if s.ReadHeaderTimeout != 500*time.Millisecond || s.IdleTimeout != 5*time.Second || s.MaxHeaderBytes != 1<<10 {
+		t.Fatalf("Expected settings to be applied, got %v, %v, %v", s.ReadHeaderTimeout, s.IdleTimeout, s.MaxHeaderBytes)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	callAndExpect(t, addr, "conn-context", http.StatusOK, "from-conn")
+
+	t.Run("Should fail - headers too large", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/conn-context", addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("X-Large", strings.Repeat("a", 16<<10))
+
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+			t.Fatalf("Expected %d got %d", http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+		}
+	})
+
+	t.Run("Should fail - invalid max header bytes", func(t *testing.T) {
+		if _, err := New(serverName, "127.0.0.1:0", WithMaxHeaderBytes(0)); err == nil {
+			t.Fatal("Expected zero max header bytes to fail")
+		}
+	})
+	t.Run("Should fail - read header timeout larger than read timeout", func(t *testing.T) {
+		if _, err := New(serverName, "127.0.0.1:0", WithReadHeaderTimeout(5*time.Second)); err == nil {
+			t.Fatal("Expected read header timeout larger than read timeout to fail")
+		}
+	})
+}
+
+func TestNew_readinessReport(t *testing.T) {
+	db := handler.NewReadinessDeterminer("db")
+	db.SetReadiness(true)
+
+	cache := handler.NewReadinessDeterminer("cache")
+	cache.SetReadinessWithReason(false, "connection refused")
+	cache.SetDetails(map[string]string{"address": "127.0.0.1:6379"})
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithReadiness(db, cache),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	t.Run("Should work - plain", func(t *testing.T) {
+		callAndExpect(t, addr, "readiness", http.StatusServiceUnavailable, "cache failed readiness")
+	})
+
+	t.Run("Should work - text", func(t *testing.T) {
+		callAndExpect(t, addr, "readiness?verbose", http.StatusServiceUnavailable, "cache: not ready, connection refused, since")
+		callAndExpect(t, addr, "readiness?verbose", http.StatusServiceUnavailable, "  address: 127.0.0.1:6379")
+	})
+
+	t.Run("Should work - JSON", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/readiness", addr), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Accept", MIMEJSON)
+
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+
+		var report handler.ReadinessReport
+
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusServiceUnavailable || report.Ready || len(report.Determiners) != 3 {
+			t.Fatalf("Unexpected report %d %+v", resp.StatusCode, report)
+		}
+
+		status := report.Determiners[2]
+
+		if status.Name != "cache" || status.Ready || status.Reason != "connection refused" ||
+			status.Details["address"] != "127.0.0.1:6379" || status.LastTransition.IsZero() {
+			t.Fatalf("Unexpected status %+v", status)
+		}
+	})
+
+	t.Run("Should work - override", func(t *testing.T) {
+		cache.SetOverride(true)
+		defer cache.ClearOverride()
+
+		callAndExpect(t, addr, "readiness?verbose", http.StatusOK, "cache: ready (overridden), connection refused")
+	})
+}
+
+func TestNew_checks(t *testing.T) {
+	var healthy int32
+
+	check, err := handler.NewCheckDeterminer("db", func(ctx context.Context) error {
+		if atomic.LoadInt32(&healthy) == 0 {
+			return errors.New("connection refused")
+		}
+
+		return nil
+	}, 10*time.Millisecond, time.Second, handler.CheckThresholds{Success: 2, Failure: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithChecks(check),
+		WithMetrics(),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	addr := waitReady(t, testServer)
+
+	waitCheck := func(ready bool) {
+		t.Helper()
+
+		for i := 0; i < 100 && check.GetReadiness() != ready; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if check.GetReadiness() != ready {
+			t.Fatalf("Expected check readiness to be %v", ready)
+		}
+	}
+
+	t.Run("Should work - failing", func(t *testing.T) {
+		for i := 0; i < 100 && check.GetReason() == ""; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		callAndExpect(t, addr, "readiness?verbose", http.StatusServiceUnavailable, "db: not ready, connection refused")
+	})
+
+	t.Run("Should work - recovered", func(t *testing.T) {
+		atomic.StoreInt32(&healthy, 1)
+
+		waitCheck(true)
+
+		callAndExpect(t, addr, "readiness", http.StatusOK, "OK")
+	})
+
+	t.Run("Should work - metrics", func(t *testing.T) {
+		checks, ok := metric.Get(serverName + "_checks").(*metric.Map)
+		if !ok || checks.Get("db") == nil {
+			t.Fatal("Expected check metrics to be published")
+		}
+
+		runs := check.Metrics().Get("runs").(*metric.Int).Value()
+		failures := check.Metrics().Get("failures").(*metric.Int).Value()
+
+		if failures < 1 || runs <= failures {
+			t.Fatalf("Unexpected check metrics, runs %d, failures %d", runs, failures)
+		}
+	})
+
+	t.Run("Should work - stopped with the server", func(t *testing.T) {
+		if err := testServer.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		runs := check.Metrics().Get("runs").(*metric.Int).Value()
+
+		time.Sleep(50 * time.Millisecond)
+
+		if check.Metrics().Get("runs").(*metric.Int).Value() != runs {
+			t.Fatal("Expected check to be stopped")
+		}
+	})
+}
+
+func TestNew_heartbeat(t *testing.T) {
+	heartbeat, err := handler.NewHeartbeatDeterminer("worker", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer heartbeat.Stop()
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithReadiness(heartbeat.ReadinessDeterminer),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	t.Run("Should work - no heartbeat yet", func(t *testing.T) {
+		callAndExpect(t, addr, "readiness?verbose", http.StatusServiceUnavailable, "worker: not ready, no heartbeat yet")
+	})
+
+	t.Run("Should work - beating", func(t *testing.T) {
+		heartbeat.Beat()
+
+		callAndExpect(t, addr, "readiness", http.StatusOK, "OK")
+
+		if heartbeat.GetLastBeat().IsZero() {
+			t.Fatal("Expected last beat to be set")
+		}
+	})
+
+	t.Run("Should work - stale", func(t *testing.T) {
+		for i := 0; i < 100 && heartbeat.GetReadiness(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		callAndExpect(t, addr, "readiness?verbose", http.StatusServiceUnavailable, "worker: not ready, stale for")
+
+		// The reason is kept current while stale.
+		reason := heartbeat.GetReason()
+
+		time.Sleep(150 * time.Millisecond)
+
+		if heartbeat.GetReason() == reason {
+			t.Fatalf("Expected reason to be updated, still %s", reason)
+		}
+	})
+
+	t.Run("Should work - recovered", func(t *testing.T) {
+		heartbeat.Beat()
+
+		callAndExpect(t, addr, "readiness", http.StatusOK, "OK")
+	})
+}
+
+func TestNew_healthz(t *testing.T) {
+	db := handler.NewReadinessDeterminer("db")
+	db.SetReadiness(true)
+
+	cache := handler.NewReadinessDeterminer("cache")
+	cache.SetReadinessWithReason(false, "connection refused")
+
+	migrations := handler.NewReadinessDeterminer("migrations")
+
+	testServer, err := New(serverName, "127.0.0.1:0",
+		WithHandlers(handler.Livez()),
+		WithReadiness(db, cache),
+		WithStartup(migrations),
+		WithTimeout(3*time.Second, 1*time.Second, 1*time.Second, 1*time.Second, 3*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = testServer.Start()
+	}()
+
+	defer testServer.Shutdown(context.Background())
+
+	addr := waitReady(t, testServer)
+
+	tests := []struct {
+		name string
+		url  string
+		sc   int
+		body string
+	}{
+		{"Should work - livez", "livez", http.StatusOK, "ok"},
+		{"Should work - livez, verbose", "livez?verbose", http.StatusOK, "[+]ping ok\nlivez check passed"},
+		{"Should fail - readyz", "readyz", http.StatusServiceUnavailable, "[+]db ok\n[-]cache failed: connection refused\nreadyz check failed"},
+		{"Should work - readyz, exclude", "readyz?exclude=cache", http.StatusOK, "ok"},
+		{"Should work - readyz, exclude, verbose", "readyz?exclude=cache&verbose", http.StatusOK, "[+]test-server ok\n[+]db ok\n[+]cache excluded: ok\nreadyz check passed"},
+		{"Should work - readyz, exclude unknown", "readyz?exclude=cache&exclude=nope&verbose", http.StatusOK, `warn: some health checks cannot be excluded: no matches for "nope"`},
+		{"Should work - readyz, single", "readyz/db", http.StatusOK, "ok"},
+		{"Should fail - readyz, single", "readyz/cache", http.StatusServiceUnavailable, "[-]cache failed: connection refused"},
+		{"Should fail - readyz, unknown", "readyz/nope", http.StatusNotFound, "no such readiness check"},
+		{"Should fail - startupz", "startupz", http.StatusServiceUnavailable, "[-]migrations failed: not ready\nstartupz check failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callAndExpect(t, addr, tt.url, tt.sc, tt.body)
+		})
+	}
+
+	t.Run("Should work - startupz, once started", func(t *testing.T) {
+		// Startup completes once ready, even if not probed meanwhile.
+		migrations.SetReadiness(true)
+		migrations.SetReadiness(false)
+
+		callAndExpect(t, addr, "startupz?verbose", http.StatusOK, "[+]migrations ok")
+	})
+}